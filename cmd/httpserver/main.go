@@ -1,20 +1,19 @@
 package main
 
 import (
-	"bytes"
 	"crypto/sha256"
-	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"strings"
 	"syscall"
 
-	"http/internal/headers"
+	"http/internal/compress"
+	"http/internal/fileserver"
+	"http/internal/negotiate"
 	"http/internal/request"
 	"http/internal/response"
 	"http/internal/server"
@@ -22,8 +21,18 @@ import (
 
 const port = 42069
 
+var assets = &fileserver.FileServer{
+	Root:            "assets",
+	Prefix:          "/assets/",
+	ListDirectories: true,
+}
+
 func main() {
-	server, err := server.Serve(port, HandlerFunc)
+	handler := server.Chain(HandlerFunc,
+		compress.DecodeRequests(compress.DefaultMaxDecodedSize),
+		compress.Middleware,
+	)
+	server, err := server.Serve(port, handler)
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
@@ -53,12 +62,17 @@ func HandlerFunc(w *response.Writer, r *request.Request) {
 		handlerVideo(w, r)
 		return
 	}
+	if strings.HasPrefix(r.RequestLine.RequestTarget, "/assets/") {
+		assets.Handle(w, r)
+		return
+	}
 	handler200(w, r)
 	return
 }
 
 func handler400(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(400)
+	rw := response.NewResponseWriter(w)
+	defer rw.Close()
 	body := `<html>
   <head>
     <title>400 Bad Request</title>
@@ -68,14 +82,14 @@ func handler400(w *response.Writer, _ *request.Request) {
     <p>Your request honestly kinda sucked.</p>
   </body>
 </html>`
-	h := response.GetDefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody([]byte(body))
+	rw.Header().Override("Content-Type", "text/html")
+	rw.WriteHeader(400)
+	rw.Write([]byte(body))
 }
 
 func handler500(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(500)
+	rw := response.NewResponseWriter(w)
+	defer rw.Close()
 	body := `<html>
   <head>
     <title>500 Internal Server Error</title>
@@ -85,14 +99,20 @@ func handler500(w *response.Writer, _ *request.Request) {
     <p>Okay, you know what? This one is on me.</p>
   </body>
 </html>`
-	h := response.GetDefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody([]byte(body))
+	rw.Header().Override("Content-Type", "text/html")
+	rw.WriteHeader(500)
+	rw.Write([]byte(body))
 }
 
-func handler200(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(200)
+func handler200(w *response.Writer, r *request.Request) {
+	offers := []string{"text/html", "application/json"}
+	contentType, ok := negotiate.ContentType(r.Headers.Get("Accept"), offers)
+	if !ok {
+		negotiate.WriteNotAcceptable(w, offers)
+		return
+	}
+	rw := response.NewResponseWriter(w)
+	defer rw.Close()
 	body := `<html>
   <head>
     <title>200 OK</title>
@@ -102,65 +122,51 @@ func handler200(w *response.Writer, _ *request.Request) {
     <p>Your request was an absolute banger.</p>
   </body>
 </html>`
-	h := response.GetDefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody([]byte(body))
+	if contentType == "application/json" {
+		body = `{"status": 200, "message": "Your request was an absolute banger."}`
+	}
+	rw.Header().Override("Content-Type", contentType)
+	rw.Header().Set("Vary", "Accept")
+	rw.WriteHeader(200)
+	rw.Write([]byte(body))
 }
 
-func handlerVideo(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(200)
-	wd, err := os.Getwd()
-	if err != nil {
-		log.Fatalf("Error getting pwd: %s", err)
-	}
-	fileData, err := os.ReadFile(filepath.Join(wd, "assets/vim.mp4"))
-	if err != nil {
-		log.Fatalf("Error reading file: %s", err)
-	}
-	h := response.GetDefaultHeaders(len(fileData))
-	h.Override("Content-Type", "video/mp4")
-	w.WriteHeaders(h)
-	w.WriteBody(fileData)
+func handlerVideo(w *response.Writer, r *request.Request) {
+	assets.ServeFile(w, r, "vim.mp4")
 }
 
 func proxyHandler(w *response.Writer, r *request.Request) {
 	lastPart := strings.TrimPrefix(r.RequestLine.RequestTarget, "/httpbin/")
 	proxyURL := fmt.Sprintf("https://httpbin.org/%s", lastPart)
+	resp, err := http.Get(proxyURL)
+	if err != nil {
+		log.Printf("Error requesting: %s", err)
+		handler500(w, r)
+		return
+	}
+	defer resp.Body.Close()
+
 	w.WriteStatusLine(200)
 	h := response.GetDefaultHeaders(0)
-	h.Remove("Content-Length")
 	h.Override("Content-Type", "application/json")
-	h.Set("Transfer-Encoding", "chunked")
 	h.Set("Trailer", "X-Content-SHA256")
 	h.Set("Trailer", "X-Content-Length")
-	w.WriteHeaders(h)
-	resp, err := http.Get(proxyURL)
+	cw, err := response.NewChunkedWriter(w, h)
 	if err != nil {
-		log.Fatalf("Error requesting: %s", err)
+		log.Printf("Error writing headers: %s", err)
+		return
 	}
-	bodyBuf := new(bytes.Buffer)
-	for {
-		buf := make([]byte, 1024)
-		n, err := resp.Body.Read(buf)
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				bodyHash := sha256.Sum256(bodyBuf.Bytes())
-				h = headers.NewHeaders()
-				h.Set("X-Content-SHA256", fmt.Sprintf("%x", bodyHash))
-				h.Set("X-Content-Length", fmt.Sprintf("%d", len(bodyBuf.Bytes())))
-				w.WriteChunkedBodyDone(h)
-
-				log.Println("Written the Whole body")
-				return
-			}
-			log.Fatalf("Error requesting: %s", err)
-		}
-		bodyBuf.Write(buf[:n])
-		log.Printf("Read Data: %d", n)
-		_, err = w.WriteChunkedBody(buf)
-		if err != nil {
-			log.Fatalf("Error requesting: %s", err)
-		}
+	hash := sha256.New()
+	n, err := io.Copy(cw, io.TeeReader(resp.Body, hash))
+	if err != nil {
+		log.Printf("Error proxying body: %s", err)
+		return
+	}
+	cw.Trailer().Set("X-Content-SHA256", fmt.Sprintf("%x", hash.Sum(nil)))
+	cw.Trailer().Set("X-Content-Length", fmt.Sprintf("%d", n))
+	if err := cw.Close(); err != nil {
+		log.Printf("Error writing trailers: %s", err)
+		return
 	}
+	log.Println("Written the Whole body")
 }
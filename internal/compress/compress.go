@@ -0,0 +1,190 @@
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+
+	"http/internal/headers"
+	"http/internal/request"
+	"http/internal/response"
+	"http/internal/server"
+)
+
+const DefaultMinSize = 1024
+
+// supported lists the codings we can produce, in order of preference.
+var supported = []string{"gzip", "deflate"}
+
+// incompressible are content types that are already compressed or are
+// streamed, where compression would only add latency.
+var incompressible = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/pdf",
+	"application/octet-stream",
+	"text/event-stream",
+}
+
+// Compressor is a middleware compressing response bodies with the coding
+// negotiated from the request's Accept-Encoding header.
+type Compressor struct {
+	// MinSize is the smallest Content-Length worth compressing. Bodies of
+	// unknown length are always compressed.
+	MinSize int
+	// Level is the compression level used by gzip and deflate.
+	Level int
+}
+
+func New() *Compressor {
+	return &Compressor{
+		MinSize: DefaultMinSize,
+		Level:   flate.DefaultCompression,
+	}
+}
+
+// Middleware returns a compression middleware with the default settings.
+func Middleware(next server.Handler) server.Handler {
+	return New().Wrap(next)
+}
+
+// Wrap returns a Handler that compresses the responses of next.
+func (c *Compressor) Wrap(next server.Handler) server.Handler {
+	return func(w *response.Writer, r *request.Request) {
+		coding := Negotiate(r.Headers.Get("Accept-Encoding"))
+		if r.RequestLine.Method == "HEAD" {
+			// no body follows, so Content-Length must describe the
+			// uncompressed one
+			coding = ""
+		}
+		w.SetBodyEncoder(func(statusCode response.StatusCode, h headers.Headers, dst io.Writer) io.WriteCloser {
+			return c.encoder(coding, statusCode, h, dst)
+		})
+		next(w, r)
+	}
+}
+
+func (c *Compressor) encoder(coding string, statusCode response.StatusCode, h headers.Headers, dst io.Writer) io.WriteCloser {
+	if !compressible(statusCode, h) {
+		return nil
+	}
+	addVary(h, "Accept-Encoding")
+	if coding == "" {
+		return nil
+	}
+	if n, ok, err := h.ContentLength(); ok && err == nil && n < int64(c.MinSize) {
+		return nil
+	}
+
+	var enc io.WriteCloser
+	var err error
+	switch coding {
+	case "gzip":
+		enc, err = gzip.NewWriterLevel(dst, c.Level)
+	case "deflate":
+		enc, err = zlib.NewWriterLevel(dst, c.Level)
+	}
+	if err != nil {
+		return nil
+	}
+	h.Override("Content-Encoding", coding)
+	// the representation changes, so strong validators no longer apply
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Override("ETag", "W/"+etag)
+	}
+	return enc
+}
+
+func compressible(statusCode response.StatusCode, h headers.Headers) bool {
+	if statusCode < 200 || statusCode == response.StatusNoContent ||
+		statusCode == response.StatusPartialContent || statusCode == response.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := strings.ToLower(strings.TrimSpace(h.Get("Content-Type")))
+	if contentType == "" {
+		return false
+	}
+	for _, prefix := range incompressible {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func addVary(h headers.Headers, field string) {
+	for _, v := range headers.SplitList(h.Get("Vary")) {
+		if v == "*" || strings.EqualFold(v, field) {
+			return
+		}
+	}
+	h.Set("Vary", field)
+}
+
+// Negotiate picks the content coding to use for an Accept-Encoding header
+// value. It returns "" when the body should be sent unencoded.
+func Negotiate(acceptEncoding string) string {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return ""
+	}
+	weights := map[string]float64{}
+	wildcard := -1.0
+	for _, item := range headers.SplitList(acceptEncoding) {
+		coding, q := parseQ(item)
+		if coding == "" {
+			continue
+		}
+		if coding == "*" {
+			wildcard = q
+			continue
+		}
+		weights[coding] = q
+	}
+	best, bestQ := "", 0.0
+	for _, coding := range supported {
+		q, ok := weights[coding]
+		if !ok && coding == "gzip" {
+			q, ok = weights["x-gzip"]
+		}
+		if !ok {
+			q = max(wildcard, 0)
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// parseQ splits a "coding;q=0.5" list element into the lower cased coding
+// and its weight.
+func parseQ(item string) (string, float64) {
+	coding, params, err := headers.ParseParameters(item)
+	if err != nil {
+		return "", 0
+	}
+	q := 1.0
+	if qValue, ok := params["q"]; ok {
+		q, err = strconv.ParseFloat(qValue, 64)
+		if err != nil || q < 0 || q > 1 {
+			return "", 0
+		}
+	}
+	return strings.ToLower(coding), q
+}
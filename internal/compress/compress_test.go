@@ -0,0 +1,220 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/headers"
+	"http/internal/request"
+	"http/internal/response"
+	"http/internal/server"
+)
+
+func run(t *testing.T, h server.Handler, acceptEncoding string) (string, string) {
+	t.Helper()
+	return runMethod(t, "GET", h, acceptEncoding)
+}
+
+func runMethod(t *testing.T, method string, h server.Handler, acceptEncoding string) (string, string) {
+	t.Helper()
+	req := method + " / HTTP/1.1\r\n"
+	if acceptEncoding != "" {
+		req += "Accept-Encoding: " + acceptEncoding + "\r\n"
+	}
+	r, err := request.RequestFromReader(strings.NewReader(req + "\r\n"))
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+	w.SetRequestMethod(method)
+	Middleware(h)(w, r)
+	require.NoError(t, w.Finish())
+	head, body, ok := strings.Cut(buf.String(), "\r\n\r\n")
+	require.True(t, ok)
+	return head, body
+}
+
+func dechunk(t *testing.T, body string) []byte {
+	t.Helper()
+	out := []byte{}
+	for {
+		sizeLine, rest, ok := strings.Cut(body, "\r\n")
+		require.True(t, ok)
+		var size int
+		_, err := fmt.Sscanf(sizeLine, "%x", &size)
+		require.NoError(t, err)
+		if size == 0 {
+			return out
+		}
+		out = append(out, rest[:size]...)
+		body = rest[size+2:]
+	}
+}
+
+func TestCompressContentLength(t *testing.T) {
+	page := strings.Repeat("<p>hello compression</p>\n", 100)
+	handler := func(w *response.Writer, _ *request.Request) {
+		rw := response.NewResponseWriter(w)
+		defer rw.Close()
+		rw.Header().Set("Content-Type", "text/html")
+		rw.Write([]byte(page))
+	}
+
+	// Test: gzip replaces Content-Length with chunked coding
+	head, body := run(t, handler, "deflate;q=0.5, gzip")
+	assert.Contains(t, head, "content-encoding: gzip\r\n")
+	assert.Contains(t, head, "vary: Accept-Encoding")
+	assert.Contains(t, head, "transfer-encoding: chunked\r\n")
+	assert.NotContains(t, head, "content-length")
+	zr, err := gzip.NewReader(bytes.NewReader(dechunk(t, body)))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, page, string(decoded))
+
+	// Test: deflate
+	head, body = run(t, handler, "gzip;q=0.1, deflate")
+	assert.Contains(t, head, "content-encoding: deflate\r\n")
+	zlr, err := zlib.NewReader(bytes.NewReader(dechunk(t, body)))
+	require.NoError(t, err)
+	decoded, err = io.ReadAll(zlr)
+	require.NoError(t, err)
+	assert.Equal(t, page, string(decoded))
+
+	// Test: No acceptable coding
+	head, body = run(t, handler, "br, gzip;q=0")
+	assert.NotContains(t, head, "content-encoding")
+	assert.Contains(t, head, "vary: Accept-Encoding")
+	assert.Equal(t, page, body)
+}
+
+func TestCompressChunked(t *testing.T) {
+	handler := func(w *response.Writer, _ *request.Request) {
+		w.WriteStatusLine(response.StatusOk)
+		h := headers.NewHeaders()
+		h.Set("Content-Type", "application/json")
+		h.Set("Trailer", "X-Count")
+		cw, err := response.NewChunkedWriter(w, h)
+		require.NoError(t, err)
+		for i := 0; i < 50; i++ {
+			cw.Write([]byte(`{"item": "value"}` + "\n"))
+		}
+		cw.Trailer().Set("X-Count", "50")
+		require.NoError(t, cw.Close())
+	}
+
+	// Test: Chunked body is compressed and keeps its trailers
+	head, body := run(t, handler, "gzip")
+	assert.Contains(t, head, "content-encoding: gzip\r\n")
+	assert.True(t, strings.HasSuffix(body, "0\r\nx-count: 50\r\n\r\n"))
+	zr, err := gzip.NewReader(bytes.NewReader(dechunk(t, body)))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat(`{"item": "value"}`+"\n", 50), string(decoded))
+}
+
+func TestCompressSkipped(t *testing.T) {
+	respond := func(contentType, body string) server.Handler {
+		return func(w *response.Writer, _ *request.Request) {
+			rw := response.NewResponseWriter(w)
+			defer rw.Close()
+			rw.Header().Set("Content-Type", contentType)
+			rw.Write([]byte(body))
+		}
+	}
+
+	// Test: Tiny bodies
+	head, body := run(t, respond("text/plain", "tiny"), "gzip")
+	assert.NotContains(t, head, "content-encoding")
+	assert.Contains(t, head, "content-length: 4\r\n")
+	assert.Equal(t, "tiny", body)
+
+	// Test: HEAD keeps the uncompressed Content-Length
+	head, body = runMethod(t, "HEAD", respond("text/html", strings.Repeat("x", 4096)), "gzip")
+	assert.NotContains(t, head, "content-encoding")
+	assert.NotContains(t, head, "transfer-encoding")
+	assert.Contains(t, head, "content-length: 4096\r\n")
+	assert.Contains(t, head, "vary: Accept-Encoding")
+	assert.Empty(t, body)
+
+	// Test: Already compressed content types
+	head, _ = run(t, respond("image/png", strings.Repeat("x", 4096)), "gzip")
+	assert.NotContains(t, head, "content-encoding")
+	assert.NotContains(t, head, "vary")
+}
+
+func TestNegotiate(t *testing.T) {
+	assert.Equal(t, "", Negotiate(""))
+	assert.Equal(t, "gzip", Negotiate("gzip, deflate"))
+	assert.Equal(t, "deflate", Negotiate("gzip;q=0.2, deflate;q=0.8"))
+	assert.Equal(t, "gzip", Negotiate("*"))
+	assert.Equal(t, "deflate", Negotiate("*;q=0.5, gzip;q=0"))
+	assert.Equal(t, "gzip", Negotiate("x-gzip"))
+	assert.Equal(t, "", Negotiate("br, identity"))
+	assert.Equal(t, "", Negotiate("gzip;q=abc"))
+}
+
+func TestDecodeRequests(t *testing.T) {
+	echo := func(w *response.Writer, r *request.Request) {
+		body, err := r.ReadBody()
+		require.NoError(t, err)
+		rw := response.NewResponseWriter(w)
+		rw.Write(body)
+		rw.Close()
+	}
+	handler := DecodeRequests(1024)(echo)
+
+	// Test: gzip request body
+	zbuf := &bytes.Buffer{}
+	zw := gzip.NewWriter(zbuf)
+	zw.Write([]byte("inflated"))
+	zw.Close()
+	r, err := request.RequestHeadersFromReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nContent-Encoding: gzip\r\nContent-Length: " +
+			fmt.Sprint(zbuf.Len()) + "\r\n\r\n" + zbuf.String()))
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	handler(response.NewWriter(buf), r)
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\ninflated"))
+
+	// Test: Decoded body over the limit is answered with 413
+	zbuf = &bytes.Buffer{}
+	zw = gzip.NewWriter(zbuf)
+	zw.Write(bytes.Repeat([]byte("a"), 4096))
+	zw.Close()
+	r, err = request.RequestHeadersFromReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nContent-Encoding: gzip\r\nContent-Length: " +
+			fmt.Sprint(zbuf.Len()) + "\r\n\r\n" + zbuf.String()))
+	require.NoError(t, err)
+	buf = &bytes.Buffer{}
+	DecodeRequests(1024)(func(w *response.Writer, r *request.Request) {
+		_, err := r.ReadBody()
+		require.ErrorIs(t, err, request.ErrBodyTooLarge)
+	})(response.NewWriter(buf), r)
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 413 "), buf.String())
+
+	// Test: Identity body is not limited
+	r, err = request.RequestHeadersFromReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nContent-Length: 2000\r\n\r\n" + strings.Repeat("b", 2000)))
+	require.NoError(t, err)
+	buf = &bytes.Buffer{}
+	handler(response.NewWriter(buf), r)
+	assert.True(t, strings.HasSuffix(buf.String(), strings.Repeat("b", 2000)))
+
+	// Test: Unsupported encoding
+	r, err = request.RequestHeadersFromReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nContent-Encoding: zstd\r\nContent-Length: 0\r\n\r\n"))
+	require.NoError(t, err)
+	buf = &bytes.Buffer{}
+	handler(response.NewWriter(buf), r)
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 415 Unsupported Media Type\r\n"))
+	assert.Contains(t, buf.String(), "accept-encoding: gzip, deflate\r\n")
+}
@@ -0,0 +1,62 @@
+package compress
+
+import (
+	"errors"
+	"io"
+
+	"http/internal/request"
+	"http/internal/response"
+	"http/internal/server"
+)
+
+const DefaultMaxDecodedSize = 10 << 20
+
+// DecodeRequests returns a middleware that transparently decodes gzip and
+// deflate request bodies, allowing at most maxSize decoded bytes. Requests
+// with other content codings are answered with 415 Unsupported Media Type.
+// When the handler stops at the size limit without responding, the request
+// is answered with 413.
+func DecodeRequests(maxSize int64) server.Middleware {
+	return func(next server.Handler) server.Handler {
+		return func(w *response.Writer, r *request.Request) {
+			encoded := r.Headers.Get("Content-Encoding") != ""
+			err := r.DecodeBody(maxSize)
+			if errors.Is(err, request.ErrUnsupportedEncoding) {
+				rw := response.NewResponseWriter(w)
+				rw.Header().Set("Accept-Encoding", "gzip, deflate")
+				rw.WriteHeader(response.StatusUnsupportedMediaType)
+				rw.Write([]byte(err.Error() + "\n"))
+				rw.Close()
+				return
+			}
+			if !encoded || r.Headers.Get("Content-Encoding") != "" {
+				// nothing was decoded, so there is no limit to report
+				next(w, r)
+				return
+			}
+			body := &limitWatcher{r: r.BodyReader()}
+			r.SetBodyReader(body)
+			next(w, r)
+			if body.exceeded && !w.StatusWritten() {
+				rw := response.NewResponseWriter(w)
+				rw.WriteHeader(response.StatusRequestEntityTooLarge)
+				rw.Write([]byte(request.ErrBodyTooLarge.Error() + "\n"))
+				rw.Close()
+			}
+		}
+	}
+}
+
+// limitWatcher records whether reading the decoded body hit the size limit.
+type limitWatcher struct {
+	r        io.Reader
+	exceeded bool
+}
+
+func (l *limitWatcher) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if errors.Is(err, request.ErrBodyTooLarge) {
+		l.exceeded = true
+	}
+	return n, err
+}
@@ -0,0 +1,223 @@
+package fileserver
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"http/internal/headers"
+	"http/internal/request"
+	"http/internal/response"
+)
+
+const indexPage = "index.html"
+
+var ErrOutsideRoot = errors.New("path resolves outside of root")
+
+// FileServer serves files from a directory tree.
+type FileServer struct {
+	// Root is the directory files are served from.
+	Root string
+	// Prefix is stripped from the request target before it is mapped to
+	// a path under Root.
+	Prefix string
+	// ListDirectories enables HTML listings for directories without an
+	// index.html.
+	ListDirectories bool
+}
+
+func New(root string) *FileServer {
+	return &FileServer{Root: root}
+}
+
+// Handle serves the file named by the request target.
+func (s *FileServer) Handle(w *response.Writer, r *request.Request) {
+	target := r.RequestLine.RequestTarget
+	if i := strings.IndexAny(target, "?#"); i != -1 {
+		target = target[:i]
+	}
+	if !strings.HasPrefix(target, s.Prefix) {
+		writeError(w, response.StatusNotFound)
+		return
+	}
+	name, err := url.PathUnescape(strings.TrimPrefix(target, s.Prefix))
+	if err != nil || strings.ContainsAny(name, "\x00\\") {
+		writeError(w, response.StatusBadRequest)
+		return
+	}
+	s.serve(w, r, name, true)
+}
+
+// ServeFile serves the file at name, relative to Root, regardless of the
+// request target.
+func (s *FileServer) ServeFile(w *response.Writer, r *request.Request, name string) {
+	s.serve(w, r, name, false)
+}
+
+func (s *FileServer) serve(w *response.Writer, r *request.Request, name string, redirect bool) {
+	method := r.RequestLine.Method
+	if method != "GET" && method != "HEAD" {
+		rw := response.NewResponseWriter(w)
+		rw.Header().Set("Allow", "GET, HEAD")
+		rw.WriteHeader(response.StatusMethodNotAllowed)
+		rw.Write([]byte("Method Not Allowed\n"))
+		rw.Close()
+		return
+	}
+
+	cleaned := path.Clean("/" + name)
+	fullPath, err := s.resolve(cleaned)
+	if err != nil {
+		writeError(w, errorStatus(err))
+		return
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		writeError(w, errorStatus(err))
+		return
+	}
+
+	if info.IsDir() {
+		if redirect && !strings.HasSuffix(name, "/") {
+			location := s.Prefix + strings.TrimPrefix(cleaned, "/") + "/"
+			if !strings.HasPrefix(location, "/") {
+				location = "/" + location
+			}
+			rw := response.NewResponseWriter(w)
+			rw.Header().Set("Location", location)
+			rw.WriteHeader(response.StatusMovedPermanently)
+			rw.Close()
+			return
+		}
+		indexPath := filepath.Join(fullPath, indexPage)
+		indexInfo, err := os.Stat(indexPath)
+		if err == nil && !indexInfo.IsDir() {
+			serveContent(w, r, indexPath, indexInfo)
+			return
+		}
+		if !s.ListDirectories {
+			writeError(w, response.StatusForbidden)
+			return
+		}
+		serveListing(w, fullPath, cleaned)
+		return
+	}
+	serveContent(w, r, fullPath, info)
+}
+
+// resolve maps a cleaned slash separated path onto the file system and makes
+// sure symlinks do not lead outside of Root.
+func (s *FileServer) resolve(cleaned string) (string, error) {
+	root, err := filepath.Abs(s.Root)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	fullPath, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(cleaned)))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrOutsideRoot
+	}
+	return fullPath, nil
+}
+
+func serveContent(w *response.Writer, r *request.Request, fullPath string, info fs.FileInfo) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		writeError(w, errorStatus(err))
+		return
+	}
+	defer f.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(fullPath))
+	if contentType == "" {
+		buf := make([]byte, sniffLen)
+		n, err := io.ReadFull(f, buf)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			writeError(w, response.StatusInternalServerError)
+			return
+		}
+		contentType = DetectContentType(buf[:n])
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			writeError(w, response.StatusInternalServerError)
+			return
+		}
+	}
+
+	h := headers.NewHeaders()
+	h.Set("Connection", "close")
+	h.Set("Content-Type", contentType)
+	h.Set("ETag", response.WeakETag(info.Size(), info.ModTime()))
+	response.SetLastModified(h, info.ModTime())
+	if err := response.ServeContent(w, r, h, f); err != nil {
+		if !w.StatusWritten() {
+			writeError(w, response.StatusInternalServerError)
+			return
+		}
+		// the response is already under way, so the client only sees it cut
+		// short
+		log.Printf("fileserver: serving %s: %v", fullPath, err)
+	}
+}
+
+func serveListing(w *response.Writer, fullPath, urlPath string) {
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		writeError(w, errorStatus(err))
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	title := html.EscapeString(urlPath)
+	rw := response.NewResponseWriter(w)
+	defer rw.Close()
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(rw, "<html>\n  <head>\n    <title>Index of %s</title>\n  </head>\n  <body>\n", title)
+	fmt.Fprintf(rw, "    <h1>Index of %s</h1>\n    <ul>\n", title)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		href := "./" + (&url.URL{Path: name}).EscapedPath()
+		fmt.Fprintf(rw, "      <li><a href=\"%s\">%s</a></li>\n", href, html.EscapeString(name))
+	}
+	fmt.Fprintf(rw, "    </ul>\n  </body>\n</html>\n")
+}
+
+func errorStatus(err error) response.StatusCode {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.ENOTDIR):
+		return response.StatusNotFound
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, ErrOutsideRoot):
+		return response.StatusForbidden
+	default:
+		return response.StatusInternalServerError
+	}
+}
+
+func writeError(w *response.Writer, statusCode response.StatusCode) {
+	rw := response.NewResponseWriter(w)
+	rw.WriteHeader(statusCode)
+	fmt.Fprintf(rw, "%d %s\n", statusCode, response.StatusText(statusCode))
+	rw.Close()
+}
@@ -0,0 +1,106 @@
+package fileserver
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/request"
+	"http/internal/response"
+)
+
+func serve(t *testing.T, s *FileServer, method, target string) string {
+	t.Helper()
+	r, err := request.RequestFromReader(strings.NewReader(method + " " + target + " HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+	w.SetRequestMethod(method)
+	s.Handle(w, r)
+	return buf.String()
+}
+
+func TestFileServer(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "page"), []byte("<html><body>hi</body></html>"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "site"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "site", "index.html"), []byte("<p>index</p>"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "files"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "files", "a <b>.txt"), []byte("a"), 0o644))
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "link")))
+
+	s := &FileServer{Root: root, Prefix: "/static/"}
+
+	// Test: Serve a file by extension
+	out := serve(t, s, "GET", "/static/hello.txt")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, out, "content-type: text/plain; charset=utf-8\r\n")
+	assert.Contains(t, out, "content-length: 5\r\n")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\nhello"))
+
+	// Test: Sniffed content type
+	out = serve(t, s, "GET", "/static/page")
+	assert.Contains(t, out, "content-type: text/html; charset=utf-8\r\n")
+
+	// Test: HEAD keeps the length but has no body
+	out = serve(t, s, "HEAD", "/static/hello.txt")
+	assert.Contains(t, out, "content-length: 5\r\n")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\n"))
+
+	// Test: Missing file
+	out = serve(t, s, "GET", "/static/missing.txt")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Traversal stays inside the root
+	out = serve(t, s, "GET", "/static/../../../etc/passwd")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 404 Not Found\r\n"))
+	out = serve(t, s, "GET", "/static/%2e%2e/%2e%2e/etc/passwd")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Symlink leading outside the root
+	out = serve(t, s, "GET", "/static/link")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 403 Forbidden\r\n"))
+	assert.NotContains(t, out, "secret")
+
+	// Test: Directory without trailing slash redirects
+	out = serve(t, s, "GET", "/static/site")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 301 Moved Permanently\r\n"))
+	assert.Contains(t, out, "location: /static/site/\r\n")
+
+	// Test: Directory resolves index.html
+	out = serve(t, s, "GET", "/static/site/")
+	assert.True(t, strings.HasSuffix(out, "<p>index</p>"))
+
+	// Test: Directory listing disabled
+	out = serve(t, s, "GET", "/static/files/")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 403 Forbidden\r\n"))
+
+	// Test: Directory listing enabled escapes names
+	s.ListDirectories = true
+	out = serve(t, s, "GET", "/static/files/")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, out, `<a href="./a%20%3Cb%3E.txt">a &lt;b&gt;.txt</a>`)
+
+	// Test: Unsupported method
+	out = serve(t, s, "POST", "/static/hello.txt")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 405 Method Not Allowed\r\n"))
+	assert.Contains(t, out, "allow: GET, HEAD\r\n")
+}
+
+func TestDetectContentType(t *testing.T) {
+	assert.Equal(t, "image/png", DetectContentType([]byte("\x89PNG\r\n\x1a\n....")))
+	assert.Equal(t, "video/mp4", DetectContentType([]byte("\x00\x00\x00\x18ftypmp42")))
+	assert.Equal(t, "image/webp", DetectContentType([]byte("RIFF\x00\x00\x00\x00WEBPVP8 ")))
+	assert.Equal(t, "text/html; charset=utf-8", DetectContentType([]byte("  <!DOCTYPE html>")))
+	assert.Equal(t, "text/plain; charset=utf-8", DetectContentType([]byte("just text")))
+	assert.Equal(t, "text/plain; charset=utf-8", DetectContentType([]byte("caf\xc3")))
+	assert.Equal(t, "application/octet-stream", DetectContentType([]byte{0x00, 0x01, 0x02}))
+}
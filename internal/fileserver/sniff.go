@@ -0,0 +1,96 @@
+package fileserver
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+const sniffLen = 512
+
+type signature struct {
+	offset      int
+	magic       []byte
+	contentType string
+}
+
+var signatures = []signature{
+	{0, []byte("%PDF-"), "application/pdf"},
+	{0, []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+	{0, []byte("\xff\xd8\xff"), "image/jpeg"},
+	{0, []byte("GIF87a"), "image/gif"},
+	{0, []byte("GIF89a"), "image/gif"},
+	{0, []byte("\x1a\x45\xdf\xa3"), "video/webm"},
+	{0, []byte("OggS\x00"), "application/ogg"},
+	{0, []byte("ID3"), "audio/mpeg"},
+	{0, []byte("PK\x03\x04"), "application/zip"},
+	{0, []byte("\x1f\x8b\x08"), "application/x-gzip"},
+	{0, []byte("wOFF"), "font/woff"},
+	{0, []byte("wOF2"), "font/woff2"},
+	{4, []byte("ftyp"), "video/mp4"},
+}
+
+var htmlPrefixes = [][]byte{
+	[]byte("<!doctype html"),
+	[]byte("<html"),
+	[]byte("<head"),
+	[]byte("<body"),
+	[]byte("<script"),
+	[]byte("<p"),
+	[]byte("<div"),
+	[]byte("<h1"),
+}
+
+// DetectContentType guesses the media type of data from its first bytes,
+// falling back to text/plain for UTF-8 text and application/octet-stream
+// for anything else.
+func DetectContentType(data []byte) string {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	for _, sig := range signatures {
+		if len(data) >= sig.offset+len(sig.magic) &&
+			bytes.Equal(data[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+			return sig.contentType
+		}
+	}
+	if bytes.HasPrefix(data, []byte("RIFF")) && len(data) >= 12 {
+		switch string(data[8:12]) {
+		case "WEBP":
+			return "image/webp"
+		case "WAVE":
+			return "audio/wav"
+		case "AVI ":
+			return "video/avi"
+		}
+	}
+
+	trimmed := bytes.TrimLeft(data, "\t\n\x0c\r ")
+	lower := bytes.ToLower(trimmed)
+	for _, prefix := range htmlPrefixes {
+		if bytes.HasPrefix(lower, prefix) && len(lower) > len(prefix) &&
+			(lower[len(prefix)] == ' ' || lower[len(prefix)] == '>') {
+			return "text/html; charset=utf-8"
+		}
+	}
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) {
+		return "text/xml; charset=utf-8"
+	}
+
+	if bytes.IndexByte(data, 0) == -1 && validUTF8Prefix(data) {
+		return "text/plain; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// validUTF8Prefix reports whether data is valid UTF-8, allowing the last rune
+// to have been cut off by the sniffing limit.
+func validUTF8Prefix(data []byte) bool {
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size <= 1 {
+			return !utf8.FullRune(data)
+		}
+		data = data[size:]
+	}
+	return true
+}
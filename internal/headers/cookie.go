@@ -0,0 +1,179 @@
+package headers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCookieName   = errors.New("invalid cookie name")
+	ErrInvalidCookieValue  = errors.New("invalid cookie value")
+	ErrInvalidCookiePath   = errors.New("invalid cookie path")
+	ErrInvalidCookieDomain = errors.New("invalid cookie domain")
+	ErrInvalidCookieExpiry = errors.New("invalid cookie expiry")
+	ErrInsecureCookie      = errors.New("cookie attribute requires Secure")
+)
+
+// SameSite is the value of a cookie's SameSite attribute.
+type SameSite int
+
+const (
+	// SameSiteDefault omits the attribute.
+	SameSiteDefault SameSite = iota
+	SameSiteLax
+	SameSiteStrict
+	SameSiteNone
+)
+
+// Cookie is an HTTP cookie as sent in a Set-Cookie header. Cookies parsed
+// from a request's Cookie header only have Name and Value set.
+type Cookie struct {
+	Name  string
+	Value string
+
+	Path    string
+	Domain  string
+	Expires time.Time
+	// MaxAge is the lifetime in seconds. Zero omits the attribute and a
+	// negative value sends Max-Age=0, deleting the cookie.
+	MaxAge      int
+	Secure      bool
+	HttpOnly    bool
+	SameSite    SameSite
+	Partitioned bool
+}
+
+// Valid reports why the cookie cannot be sent, or nil if it can.
+func (c *Cookie) Valid() error {
+	if !ValidFieldName(c.Name) {
+		return ErrInvalidCookieName
+	}
+	if !validCookieValue(c.Value) {
+		return ErrInvalidCookieValue
+	}
+	if !validCookieAttribute(c.Path) {
+		return ErrInvalidCookiePath
+	}
+	if !validCookieDomain(c.Domain) {
+		return ErrInvalidCookieDomain
+	}
+	if !c.Expires.IsZero() && c.Expires.UTC().Year() < 1601 {
+		return ErrInvalidCookieExpiry
+	}
+	if (c.SameSite == SameSiteNone || c.Partitioned) && !c.Secure {
+		return ErrInsecureCookie
+	}
+	return nil
+}
+
+// String returns the Set-Cookie field value for the cookie. The cookie is
+// not validated.
+func (c *Cookie) String() string {
+	var b strings.Builder
+	b.WriteString(c.Name)
+	b.WriteByte('=')
+	b.WriteString(c.Value)
+	if c.Path != "" {
+		b.WriteString("; Path=")
+		b.WriteString(c.Path)
+	}
+	if c.Domain != "" {
+		b.WriteString("; Domain=")
+		b.WriteString(strings.TrimPrefix(c.Domain, "."))
+	}
+	if !c.Expires.IsZero() {
+		b.WriteString("; Expires=")
+		b.WriteString(FormatHTTPDate(c.Expires))
+	}
+	if c.MaxAge > 0 {
+		b.WriteString("; Max-Age=")
+		b.WriteString(strconv.Itoa(c.MaxAge))
+	} else if c.MaxAge < 0 {
+		b.WriteString("; Max-Age=0")
+	}
+	if c.Secure {
+		b.WriteString("; Secure")
+	}
+	if c.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+	switch c.SameSite {
+	case SameSiteLax:
+		b.WriteString("; SameSite=Lax")
+	case SameSiteStrict:
+		b.WriteString("; SameSite=Strict")
+	case SameSiteNone:
+		b.WriteString("; SameSite=None")
+	}
+	if c.Partitioned {
+		b.WriteString("; Partitioned")
+	}
+	return b.String()
+}
+
+// ParseCookies parses the value of a Cookie header. Pairs with an invalid
+// name or value are skipped. Multiple Cookie fields joined by Set with a
+// comma are also accepted, since cookie values cannot contain commas.
+func ParseCookies(value string) []*Cookie {
+	var cookies []*Cookie
+	for _, pair := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ','
+	}) {
+		name, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !ValidFieldName(name) {
+			continue
+		}
+		if len(val) > 1 && val[0] == '"' && val[len(val)-1] == '"' {
+			val = val[1 : len(val)-1]
+		}
+		if !validCookieValue(val) {
+			continue
+		}
+		cookies = append(cookies, &Cookie{Name: name, Value: val})
+	}
+	return cookies
+}
+
+// validCookieValue checks the cookie-octet grammar of RFC 6265, allowing the
+// value to be wrapped in double quotes.
+func validCookieValue(value string) bool {
+	if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c <= ' ' || c >= 0x7f || c == '"' || c == ',' || c == ';' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+func validCookieAttribute(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < ' ' || c >= 0x7f || c == ';' {
+			return false
+		}
+	}
+	return true
+}
+
+func validCookieDomain(domain string) bool {
+	if domain == "" {
+		return true
+	}
+	for _, label := range strings.Split(strings.TrimPrefix(domain, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !isLetter(r) && !isDigit(r) && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
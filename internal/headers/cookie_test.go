@@ -0,0 +1,65 @@
+package headers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCookies(t *testing.T) {
+	cookies := ParseCookies(`a=1; b="two"; bad name=x; c=; d=x y, e=5`)
+	require.Len(t, cookies, 4)
+	assert.Equal(t, &Cookie{Name: "a", Value: "1"}, cookies[0])
+	assert.Equal(t, &Cookie{Name: "b", Value: "two"}, cookies[1])
+	assert.Equal(t, &Cookie{Name: "c", Value: ""}, cookies[2])
+	assert.Equal(t, &Cookie{Name: "e", Value: "5"}, cookies[3])
+	assert.Nil(t, ParseCookies(""))
+}
+
+func TestCookieString(t *testing.T) {
+	c := &Cookie{
+		Name:        "session",
+		Value:       "abc",
+		Path:        "/app",
+		Domain:      ".example.com",
+		Expires:     time.Date(2015, time.October, 21, 7, 28, 0, 0, time.UTC),
+		MaxAge:      3600,
+		Secure:      true,
+		HttpOnly:    true,
+		SameSite:    SameSiteNone,
+		Partitioned: true,
+	}
+	require.NoError(t, c.Valid())
+	assert.Equal(t,
+		"session=abc; Path=/app; Domain=example.com; Expires=Wed, 21 Oct 2015 07:28:00 GMT; "+
+			"Max-Age=3600; Secure; HttpOnly; SameSite=None; Partitioned",
+		c.String())
+	assert.Equal(t, "a=b; SameSite=Lax", (&Cookie{Name: "a", Value: "b", SameSite: SameSiteLax}).String())
+}
+
+func TestCookieValid(t *testing.T) {
+	tests := []struct {
+		cookie Cookie
+		err    error
+	}{
+		{Cookie{Name: "", Value: "x"}, ErrInvalidCookieName},
+		{Cookie{Name: "a=b", Value: "x"}, ErrInvalidCookieName},
+		{Cookie{Name: "a", Value: "x;y"}, ErrInvalidCookieValue},
+		{Cookie{Name: "a", Value: `"x"`}, nil},
+		{Cookie{Name: "a", Value: "x", Path: "/a;b"}, ErrInvalidCookiePath},
+		{Cookie{Name: "a", Value: "x", Domain: "exa mple.com"}, ErrInvalidCookieDomain},
+		{Cookie{Name: "a", Value: "x", Domain: "-example.com"}, ErrInvalidCookieDomain},
+		{Cookie{Name: "a", Value: "x", Expires: time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC)}, ErrInvalidCookieExpiry},
+		{Cookie{Name: "a", Value: "x", SameSite: SameSiteNone}, ErrInsecureCookie},
+		{Cookie{Name: "a", Value: "x", Partitioned: true}, ErrInsecureCookie},
+	}
+	for _, tt := range tests {
+		if tt.err == nil {
+			assert.NoError(t, tt.cookie.Valid(), tt.cookie.String())
+		} else {
+			assert.ErrorIs(t, tt.cookie.Valid(), tt.err, tt.cookie.String())
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package headers
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidParameter = errors.New("invalid header parameter")
+
+// SplitList splits a comma separated header value into its trimmed, non
+// empty elements. Commas inside quoted strings do not split.
+func SplitList(value string) []string {
+	var list []string
+	for _, item := range splitOutsideQuotes(value, ',') {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// SplitParameters splits a value on the semicolons that separate its
+// parameters. Semicolons inside quoted strings do not split, and the parts
+// are returned untrimmed.
+func SplitParameters(value string) []string {
+	return splitOutsideQuotes(value, ';')
+}
+
+// ParseParameters parses a value followed by semicolon separated parameters,
+// such as "text/html; charset=utf-8". Parameter names are lower cased and
+// quoted values are unquoted.
+func ParseParameters(value string) (string, map[string]string, error) {
+	parts := SplitParameters(value)
+	params := map[string]string{}
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || !ValidFieldName(name) {
+			return "", nil, ErrInvalidParameter
+		}
+		val = strings.TrimSpace(val)
+		if strings.HasPrefix(val, `"`) {
+			unquoted, err := Unquote(val)
+			if err != nil {
+				return "", nil, err
+			}
+			val = unquoted
+		} else if !ValidFieldName(val) {
+			return "", nil, ErrInvalidParameter
+		}
+		params[name] = val
+	}
+	return strings.TrimSpace(parts[0]), params, nil
+}
+
+// FormatParameters is the inverse of ParseParameters. Parameters are written
+// in sorted order, quoting values that are not tokens.
+func FormatParameters(value string, params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(value)
+	for _, name := range names {
+		b.WriteString("; ")
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(QuoteIfNeeded(params[name]))
+	}
+	return b.String()
+}
+
+// Quote returns s as a quoted-string, escaping quotes and backslashes.
+func Quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// QuoteIfNeeded returns s unchanged if it is a token and quoted otherwise.
+func QuoteIfNeeded(s string) string {
+	if ValidFieldName(s) {
+		return s
+	}
+	return Quote(s)
+}
+
+// Unquote parses a quoted-string, removing the quotes and escapes.
+func Unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", ErrInvalidParameter
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		if c == '\\' {
+			i++
+			if i == len(s)-1 {
+				return "", ErrInvalidParameter
+			}
+			c = s[i]
+		} else if c == '"' {
+			return "", ErrInvalidParameter
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,134 @@
+package headers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, SplitList(" a, b ,,c "))
+	assert.Equal(t, []string{`"x, y"`, `W/"z"`}, SplitList(`"x, y", W/"z"`))
+	assert.Nil(t, SplitList(""))
+}
+
+func TestSplitParameters(t *testing.T) {
+	assert.Equal(t, []string{"text/html", " q=0.5", ` ext="a;b"`}, SplitParameters(`text/html; q=0.5; ext="a;b"`))
+	assert.Equal(t, []string{"gzip"}, SplitParameters("gzip"))
+}
+
+func TestParseParameters(t *testing.T) {
+	// Test: Token and quoted values
+	value, params, err := ParseParameters(`text/html; Charset=utf-8; title="a \"b\"; c"`)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", value)
+	assert.Equal(t, map[string]string{"charset": "utf-8", "title": `a "b"; c`}, params)
+
+	// Test: No parameters
+	value, params, err = ParseParameters("gzip")
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", value)
+	assert.Empty(t, params)
+
+	// Test: Invalid parameters
+	_, _, err = ParseParameters("text/html; charset")
+	require.ErrorIs(t, err, ErrInvalidParameter)
+	_, _, err = ParseParameters("text/html; charset=a b")
+	require.ErrorIs(t, err, ErrInvalidParameter)
+
+	// Test: Round trip
+	assert.Equal(t, `text/plain; charset=utf-8; name="a b"`,
+		FormatParameters("text/plain", map[string]string{"name": "a b", "charset": "utf-8"}))
+}
+
+func TestStructuredFieldItem(t *testing.T) {
+	// Test: Bare item types
+	cases := map[string]any{
+		"42":          int64(42),
+		"-17":         int64(-17),
+		"4.5":         4.5,
+		`"hi \"x\""`:  `hi "x"`,
+		"foo/bar:baz": Token("foo/bar:baz"),
+		"*star":       Token("*star"),
+		":aGVsbG8=:":  []byte("hello"),
+		"?1":          true,
+		"?0":          false,
+	}
+	for in, want := range cases {
+		item, err := ParseItem(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, item.Value, in)
+		out, err := SerializeItem(item)
+		require.NoError(t, err)
+		assert.Equal(t, in, out)
+	}
+
+	// Test: Parameters
+	item, err := ParseItem(`text/html;q=0.5;a;b="x"`)
+	require.NoError(t, err)
+	assert.Equal(t, Params{{"q", 0.5}, {"a", true}, {"b", "x"}}, item.Params)
+	q, ok := item.Params.Get("q")
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, q)
+
+	// Test: Invalid items
+	for _, in := range []string{"", "1234567890123456", "1.2345", "\"open", "?2", ":bad base64:", "a b", "1;A=1"} {
+		_, err := ParseItem(in)
+		require.ErrorIs(t, err, ErrInvalidStructuredField, in)
+	}
+
+	// Test: Decimal serialization rounds to three digits
+	out, err := SerializeItem(Item{Value: 1.00049})
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", out)
+	out, err = SerializeItem(Item{Value: 2.5})
+	require.NoError(t, err)
+	assert.Equal(t, "2.5", out)
+}
+
+func TestStructuredFieldList(t *testing.T) {
+	list, err := ParseStructuredList(`sugar, tea;hot, (rum "lime");n=2, ()`)
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	assert.Equal(t, Item{Value: Token("sugar")}, list[0])
+	assert.Equal(t, Item{Value: Token("tea"), Params: Params{{"hot", true}}}, list[1])
+	assert.Equal(t, InnerList{
+		Items:  []Item{{Value: Token("rum")}, {Value: "lime"}},
+		Params: Params{{"n", int64(2)}},
+	}, list[2])
+	assert.Equal(t, InnerList{Items: []Item{}}, list[3])
+
+	out, err := SerializeList(list)
+	require.NoError(t, err)
+	assert.Equal(t, `sugar, tea;hot, (rum "lime");n=2, ()`, out)
+
+	_, err = ParseStructuredList("a, b,")
+	require.ErrorIs(t, err, ErrInvalidStructuredField)
+	_, err = ParseStructuredList("(a b")
+	require.ErrorIs(t, err, ErrInvalidStructuredField)
+
+	empty, err := ParseStructuredList("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestStructuredFieldDictionary(t *testing.T) {
+	dict, err := ParseDictionary(`a=1, b, c=(x y);p, a=2, d;q=?0`)
+	require.NoError(t, err)
+	require.Len(t, dict, 4)
+	assert.Equal(t, "a", dict[0].Key)
+	assert.Equal(t, Item{Value: int64(2)}, dict[0].Member)
+	b, ok := dict.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, Item{Value: true}, b)
+
+	out, err := SerializeDictionary(dict)
+	require.NoError(t, err)
+	assert.Equal(t, `a=2, b, c=(x y);p, d;q=?0`, out)
+
+	_, err = ParseDictionary("A=1")
+	require.ErrorIs(t, err, ErrInvalidStructuredField)
+	_, err = SerializeDictionary(Dictionary{{Key: "Bad", Member: Item{Value: int64(1)}}})
+	require.ErrorIs(t, err, ErrInvalidStructuredField)
+}
@@ -14,34 +14,33 @@ func NewHeaders() Headers {
 	return map[string]string{}
 }
 
+// Parse parses a single field line into h with a strict Parser. done is
+// true once the empty line ending the header section has been consumed.
 func (h Headers) Parse(data []byte) (n int, done bool, err error) {
-	idx := bytes.Index(data, []byte(crlf))
-	if idx == -1 {
-		return 0, false, nil
-	}
-	if idx == 0 {
-		return 2, true, nil
-	}
+	var p Parser
+	return p.Parse(h, data)
+}
 
-	parts := bytes.SplitN(data[:idx], []byte(":"), 2)
+func parseFieldLine(h Headers, line []byte) error {
+	parts := bytes.SplitN(line, []byte(":"), 2)
 
 	if len(parts) < 2 {
-		return 0, false, errors.New("invalid header")
+		return errors.New("invalid header")
 	}
 
 	key := string(parts[0])
 	if key != strings.TrimRight(key, " ") {
-		return 0, false, errors.New("invalid header name")
+		return errors.New("invalid header name")
 	}
 
 	value := bytes.TrimSpace(parts[1])
 	key = strings.TrimSpace(key)
 	if !checkKey(key) {
-		return 0, false, errors.New("invalid header name character")
+		return errors.New("invalid header name character")
 	}
 
 	h.Set(key, string(value))
-	return idx + 2, false, nil
+	return nil
 }
 
 func (h Headers) Set(key, value string) {
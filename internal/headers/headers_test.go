@@ -77,3 +77,54 @@ func TestHeadersParse(t *testing.T) {
 	assert.Equal(t, 19, n)
 	assert.False(t, done)
 }
+
+func TestParserLenient(t *testing.T) {
+	// Test: Strict mode rejects bare LF
+	h := NewHeaders()
+	var strict Parser
+	_, _, err := strict.Parse(h, []byte("Host: localhost\nAccept: */*\r\n\r\n"))
+	require.ErrorIs(t, err, ErrBareLF)
+
+	// Test: Bare LF
+	h = NewHeaders()
+	p := Parser{Mode: Lenient}
+	data := []byte("Host: localhost\nAccept: */*\r\n\n")
+	n, done, err := p.Parse(h, data)
+	require.NoError(t, err)
+	assert.Equal(t, 16, n)
+	assert.False(t, done)
+	assert.Equal(t, "localhost", h["host"])
+	assert.Equal(t, LenientBareLF, p.Applied)
+	data = data[n:]
+	n, _, err = p.Parse(h, data)
+	require.NoError(t, err)
+	data = data[n:]
+	n, done, err = p.Parse(h, data)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, done)
+
+	// Test: obs-fold is replaced with SP
+	h = NewHeaders()
+	p = Parser{Mode: Lenient}
+	data = []byte("X-Folded: a  \r\n \t b\r\n\tc\r\nHost: x\r\n")
+	n, done, err = p.Parse(h, data)
+	require.NoError(t, err)
+	assert.Equal(t, 25, n)
+	assert.False(t, done)
+	assert.Equal(t, "a b c", h["x-folded"])
+	assert.Equal(t, LenientObsFold, p.Applied)
+	assert.Equal(t, "obs-fold", p.Applied.String())
+
+	// Test: Line is held back until the next line starts
+	h = NewHeaders()
+	p = Parser{Mode: Lenient}
+	n, _, err = p.Parse(h, []byte("X-Folded: a\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Empty(t, h)
+	n, _, err = p.Parse(h, []byte("X-Folded: a\r\n b"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Zero(t, p.Applied)
+}
@@ -0,0 +1,117 @@
+package headers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// Mode selects how strictly a Parser follows the field line grammar.
+type Mode int
+
+const (
+	// Strict accepts only CRLF terminated field lines.
+	Strict Mode = iota
+	// Lenient also accepts bare LF line endings and obs-fold continuation
+	// lines, as still sent by some legacy clients.
+	Lenient
+)
+
+// Leniency is a set of deviations from the strict grammar that a lenient
+// parse accepted.
+type Leniency uint8
+
+const (
+	LenientObsFold Leniency = 1 << iota
+	LenientBareLF
+)
+
+// Has reports whether all leniencies in l2 are set in l.
+func (l Leniency) Has(l2 Leniency) bool {
+	return l&l2 == l2
+}
+
+func (l Leniency) String() string {
+	var names []string
+	if l.Has(LenientObsFold) {
+		names = append(names, "obs-fold")
+	}
+	if l.Has(LenientBareLF) {
+		names = append(names, "bare-lf")
+	}
+	return strings.Join(names, ", ")
+}
+
+var ErrBareLF = errors.New("bare LF in header section")
+
+// Parser parses field lines in the given Mode and records the leniencies
+// that were applied in Applied. The zero value is a strict parser.
+type Parser struct {
+	Mode    Mode
+	Applied Leniency
+}
+
+// Parse parses a single field line into h, like Headers.Parse. In lenient
+// mode the line is only consumed once the first byte of the next line is
+// available, so that obs-fold continuation lines can be joined to it.
+func (p *Parser) Parse(h Headers, data []byte) (n int, done bool, err error) {
+	line, n, applied, err := p.readLine(data, 0)
+	if err != nil || n == 0 {
+		return 0, false, err
+	}
+	if len(line) == 0 {
+		p.Applied |= applied
+		return n, true, nil
+	}
+	if p.Mode == Lenient {
+		for {
+			if n >= len(data) {
+				return 0, false, nil
+			}
+			if data[n] != ' ' && data[n] != '\t' {
+				break
+			}
+			next, m, lineApplied, _ := p.readLine(data, n)
+			if m == 0 {
+				return 0, false, nil
+			}
+			// obs-fold is replaced with a single SP
+			line = append(bytes.TrimRight(line, " \t"), ' ')
+			line = append(line, bytes.TrimLeft(next, " \t")...)
+			applied |= lineApplied | LenientObsFold
+			n += m
+		}
+	}
+
+	if err := parseFieldLine(h, line); err != nil {
+		return 0, false, err
+	}
+	p.Applied |= applied
+	return n, false, nil
+}
+
+// readLine returns the line starting at data[start:] without its line
+// ending, and the number of bytes consumed including the line ending. It
+// consumes 0 bytes when the line is incomplete.
+func (p *Parser) readLine(data []byte, start int) (line []byte, n int, applied Leniency, err error) {
+	data = data[start:]
+	if p.Mode != Lenient {
+		idx := bytes.Index(data, []byte(crlf))
+		lf := bytes.IndexByte(data, '\n')
+		if lf != -1 && (idx == -1 || lf < idx+1) {
+			return nil, 0, 0, ErrBareLF
+		}
+		if idx == -1 {
+			return nil, 0, 0, nil
+		}
+		return data[:idx:idx], idx + 2, 0, nil
+	}
+	idx := bytes.IndexByte(data, '\n')
+	if idx == -1 {
+		return nil, 0, 0, nil
+	}
+	if idx > 0 && data[idx-1] == '\r' {
+		return data[: idx-1 : idx-1], idx + 1, 0, nil
+	}
+	return data[:idx:idx], idx + 1, LenientBareLF, nil
+}
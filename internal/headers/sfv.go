@@ -0,0 +1,606 @@
+package headers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Structured Field Values as defined by RFC 8941. Bare item values are
+// represented as int64, float64, string, Token, []byte or bool.
+
+var ErrInvalidStructuredField = errors.New("invalid structured field value")
+
+// Token is a structured field token, as opposed to a string.
+type Token string
+
+// Param is a single parameter of an item or inner list.
+type Param struct {
+	Key   string
+	Value any
+}
+
+// Params is an ordered set of parameters.
+type Params []Param
+
+// Get returns the value of the parameter named key.
+func (p Params) Get(key string) (any, bool) {
+	for _, param := range p {
+		if param.Key == key {
+			return param.Value, true
+		}
+	}
+	return nil, false
+}
+
+// set overwrites an existing key in place, keeping its position.
+func (p Params) set(key string, value any) Params {
+	for i := range p {
+		if p[i].Key == key {
+			p[i].Value = value
+			return p
+		}
+	}
+	return append(p, Param{Key: key, Value: value})
+}
+
+// Item is a bare item with parameters.
+type Item struct {
+	Value  any
+	Params Params
+}
+
+// InnerList is a parenthesised list of items with parameters.
+type InnerList struct {
+	Items  []Item
+	Params Params
+}
+
+// Member is an element of a List or Dictionary: an Item or an InnerList.
+type Member interface {
+	member()
+}
+
+func (Item) member()      {}
+func (InnerList) member() {}
+
+// List is a structured field list.
+type List []Member
+
+// DictMember is a keyed member of a Dictionary.
+type DictMember struct {
+	Key    string
+	Member Member
+}
+
+// Dictionary is an ordered structured field dictionary.
+type Dictionary []DictMember
+
+// Get returns the member named key.
+func (d Dictionary) Get(key string) (Member, bool) {
+	for _, m := range d {
+		if m.Key == key {
+			return m.Member, true
+		}
+	}
+	return nil, false
+}
+
+// ParseItem parses a structured field item.
+func ParseItem(value string) (Item, error) {
+	p := &sfParser{s: strings.Trim(value, " ")}
+	item, err := p.parseItem()
+	if err != nil {
+		return Item{}, err
+	}
+	if !p.done() {
+		return Item{}, p.errorf("trailing characters")
+	}
+	return item, nil
+}
+
+// ParseStructuredList parses a structured field list.
+func ParseStructuredList(value string) (List, error) {
+	p := &sfParser{s: strings.Trim(value, " ")}
+	list := List{}
+	for !p.done() {
+		member, err := p.parseMember()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, member)
+		if err := p.nextMember(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// ParseDictionary parses a structured field dictionary. Later duplicate keys
+// overwrite earlier ones but keep their position.
+func ParseDictionary(value string) (Dictionary, error) {
+	p := &sfParser{s: strings.Trim(value, " ")}
+	dict := Dictionary{}
+	for !p.done() {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var member Member
+		if p.peek() == '=' {
+			p.pos++
+			member, err = p.parseMember()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			params, err := p.parseParams()
+			if err != nil {
+				return nil, err
+			}
+			member = Item{Value: true, Params: params}
+		}
+		replaced := false
+		for i := range dict {
+			if dict[i].Key == key {
+				dict[i].Member = member
+				replaced = true
+			}
+		}
+		if !replaced {
+			dict = append(dict, DictMember{Key: key, Member: member})
+		}
+		if err := p.nextMember(); err != nil {
+			return nil, err
+		}
+	}
+	return dict, nil
+}
+
+type sfParser struct {
+	s   string
+	pos int
+}
+
+func (p *sfParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *sfParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *sfParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s at %d", ErrInvalidStructuredField, fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *sfParser) skipOWS() {
+	for !p.done() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *sfParser) skipSP() {
+	for !p.done() && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// nextMember consumes the separator between list or dictionary members.
+func (p *sfParser) nextMember() error {
+	p.skipOWS()
+	if p.done() {
+		return nil
+	}
+	if p.peek() != ',' {
+		return p.errorf("expected comma")
+	}
+	p.pos++
+	p.skipOWS()
+	if p.done() {
+		return p.errorf("trailing comma")
+	}
+	return nil
+}
+
+func (p *sfParser) parseMember() (Member, error) {
+	if p.peek() == '(' {
+		return p.parseInnerList()
+	}
+	return p.parseItem()
+}
+
+func (p *sfParser) parseInnerList() (InnerList, error) {
+	p.pos++ // (
+	inner := InnerList{Items: []Item{}}
+	for !p.done() {
+		p.skipSP()
+		if p.peek() == ')' {
+			p.pos++
+			params, err := p.parseParams()
+			if err != nil {
+				return InnerList{}, err
+			}
+			inner.Params = params
+			return inner, nil
+		}
+		item, err := p.parseItem()
+		if err != nil {
+			return InnerList{}, err
+		}
+		inner.Items = append(inner.Items, item)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return InnerList{}, p.errorf("expected space or ')' in inner list")
+		}
+	}
+	return InnerList{}, p.errorf("unterminated inner list")
+}
+
+func (p *sfParser) parseItem() (Item, error) {
+	value, err := p.parseBareItem()
+	if err != nil {
+		return Item{}, err
+	}
+	params, err := p.parseParams()
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{Value: value, Params: params}, nil
+}
+
+func (p *sfParser) parseParams() (Params, error) {
+	var params Params
+	for p.peek() == ';' {
+		p.pos++
+		p.skipSP()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var value any = true
+		if p.peek() == '=' {
+			p.pos++
+			value, err = p.parseBareItem()
+			if err != nil {
+				return nil, err
+			}
+		}
+		params = params.set(key, value)
+	}
+	return params, nil
+}
+
+func (p *sfParser) parseKey() (string, error) {
+	start := p.pos
+	c := p.peek()
+	if !isLCAlpha(c) && c != '*' {
+		return "", p.errorf("invalid key")
+	}
+	for !p.done() {
+		c := p.s[p.pos]
+		if !isLCAlpha(c) && !isDigitByte(c) && c != '_' && c != '-' && c != '.' && c != '*' {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *sfParser) parseBareItem() (any, error) {
+	c := p.peek()
+	switch {
+	case c == '-' || isDigitByte(c):
+		return p.parseNumber()
+	case c == '"':
+		return p.parseString()
+	case c == '*' || isAlphaByte(c):
+		return p.parseToken(), nil
+	case c == ':':
+		return p.parseByteSequence()
+	case c == '?':
+		return p.parseBoolean()
+	default:
+		return nil, p.errorf("invalid bare item")
+	}
+}
+
+func (p *sfParser) parseNumber() (any, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	if !isDigitByte(p.peek()) {
+		return nil, p.errorf("expected digit")
+	}
+	digitsStart := p.pos
+	dot := -1
+	for !p.done() {
+		c := p.s[p.pos]
+		if c == '.' && dot == -1 {
+			if p.pos-digitsStart > 12 {
+				return nil, p.errorf("decimal integer part too long")
+			}
+			dot = p.pos
+		} else if !isDigitByte(c) {
+			break
+		}
+		p.pos++
+		if dot == -1 && p.pos-digitsStart > 15 {
+			return nil, p.errorf("integer too long")
+		}
+	}
+	text := p.s[start:p.pos]
+	if dot == -1 {
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer")
+		}
+		return n, nil
+	}
+	if fraction := p.pos - dot - 1; fraction < 1 || fraction > 3 {
+		return nil, p.errorf("invalid decimal fraction")
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, p.errorf("invalid decimal")
+	}
+	return f, nil
+}
+
+func (p *sfParser) parseString() (string, error) {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for !p.done() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			if p.done() {
+				return "", p.errorf("unterminated escape")
+			}
+			next := p.s[p.pos]
+			if next != '"' && next != '\\' {
+				return "", p.errorf("invalid escape")
+			}
+			b.WriteByte(next)
+			p.pos++
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", p.errorf("invalid string character")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *sfParser) parseToken() Token {
+	start := p.pos
+	p.pos++
+	for !p.done() {
+		c := p.s[p.pos]
+		if !isTChar(c) && c != ':' && c != '/' {
+			break
+		}
+		p.pos++
+	}
+	return Token(p.s[start:p.pos])
+}
+
+func (p *sfParser) parseByteSequence() ([]byte, error) {
+	p.pos++ // opening colon
+	end := strings.IndexByte(p.s[p.pos:], ':')
+	if end == -1 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+	encoded := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, p.errorf("invalid base64")
+	}
+	return data, nil
+}
+
+func (p *sfParser) parseBoolean() (bool, error) {
+	p.pos++ // ?
+	switch p.peek() {
+	case '1':
+		p.pos++
+		return true, nil
+	case '0':
+		p.pos++
+		return false, nil
+	default:
+		return false, p.errorf("invalid boolean")
+	}
+}
+
+// SerializeItem serializes a structured field item.
+func SerializeItem(item Item) (string, error) {
+	var b strings.Builder
+	if err := serializeItem(&b, item); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// SerializeList serializes a structured field list.
+func SerializeList(list List) (string, error) {
+	var b strings.Builder
+	for i, member := range list {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if err := serializeMember(&b, member); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// SerializeDictionary serializes a structured field dictionary. Members
+// that are the boolean true are written as the bare key.
+func SerializeDictionary(dict Dictionary) (string, error) {
+	var b strings.Builder
+	for i, m := range dict {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if err := serializeKey(&b, m.Key); err != nil {
+			return "", err
+		}
+		if item, ok := m.Member.(Item); ok && item.Value == true {
+			if err := serializeParams(&b, item.Params); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte('=')
+		if err := serializeMember(&b, m.Member); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+func serializeMember(b *strings.Builder, member Member) error {
+	switch m := member.(type) {
+	case Item:
+		return serializeItem(b, m)
+	case InnerList:
+		b.WriteByte('(')
+		for i, item := range m.Items {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			if err := serializeItem(b, item); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+		return serializeParams(b, m.Params)
+	default:
+		return fmt.Errorf("%w: unknown member type %T", ErrInvalidStructuredField, member)
+	}
+}
+
+func serializeItem(b *strings.Builder, item Item) error {
+	if err := serializeBareItem(b, item.Value); err != nil {
+		return err
+	}
+	return serializeParams(b, item.Params)
+}
+
+func serializeParams(b *strings.Builder, params Params) error {
+	for _, param := range params {
+		b.WriteByte(';')
+		if err := serializeKey(b, param.Key); err != nil {
+			return err
+		}
+		if param.Value == true {
+			continue
+		}
+		b.WriteByte('=')
+		if err := serializeBareItem(b, param.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func serializeKey(b *strings.Builder, key string) error {
+	if key == "" || (!isLCAlpha(key[0]) && key[0] != '*') {
+		return fmt.Errorf("%w: invalid key %q", ErrInvalidStructuredField, key)
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !isLCAlpha(c) && !isDigitByte(c) && c != '_' && c != '-' && c != '.' && c != '*' {
+			return fmt.Errorf("%w: invalid key %q", ErrInvalidStructuredField, key)
+		}
+	}
+	b.WriteString(key)
+	return nil
+}
+
+func serializeBareItem(b *strings.Builder, value any) error {
+	switch v := value.(type) {
+	case int:
+		return serializeBareItem(b, int64(v))
+	case int64:
+		if v > 999_999_999_999_999 || v < -999_999_999_999_999 {
+			return fmt.Errorf("%w: integer out of range", ErrInvalidStructuredField)
+		}
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		rounded := math.RoundToEven(v*1000) / 1000
+		if math.Abs(rounded) >= 1e12 || math.IsNaN(rounded) {
+			return fmt.Errorf("%w: decimal out of range", ErrInvalidStructuredField)
+		}
+		s := strconv.FormatFloat(rounded, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		b.WriteString(s)
+	case string:
+		b.WriteByte('"')
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			if c < 0x20 || c > 0x7e {
+				return fmt.Errorf("%w: invalid string character", ErrInvalidStructuredField)
+			}
+			if c == '"' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+		b.WriteByte('"')
+	case Token:
+		if v == "" || (v[0] != '*' && !isAlphaByte(v[0])) {
+			return fmt.Errorf("%w: invalid token %q", ErrInvalidStructuredField, v)
+		}
+		for i := 1; i < len(v); i++ {
+			if !isTChar(v[i]) && v[i] != ':' && v[i] != '/' {
+				return fmt.Errorf("%w: invalid token %q", ErrInvalidStructuredField, v)
+			}
+		}
+		b.WriteString(string(v))
+	case []byte:
+		b.WriteByte(':')
+		b.WriteString(base64.StdEncoding.EncodeToString(v))
+		b.WriteByte(':')
+	case bool:
+		if v {
+			b.WriteString("?1")
+		} else {
+			b.WriteString("?0")
+		}
+	default:
+		return fmt.Errorf("%w: unsupported type %T", ErrInvalidStructuredField, value)
+	}
+	return nil
+}
+
+func isLCAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func isAlphaByte(c byte) bool {
+	return isLetter(rune(c))
+}
+
+func isDigitByte(c byte) bool {
+	return isDigit(rune(c))
+}
+
+func isTChar(c byte) bool {
+	r := rune(c)
+	return isLetter(r) || isDigit(r) || isSpecial(r)
+}
@@ -0,0 +1,170 @@
+package headers
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat is the preferred IMF-fixdate format of HTTP dates.
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+const (
+	rfc850Format  = "Monday, 02-Jan-06 15:04:05 GMT"
+	asctimeFormat = "Mon Jan _2 15:04:05 2006"
+)
+
+var (
+	ErrInvalidContentLength = errors.New("invalid content length")
+	ErrInvalidDate          = errors.New("invalid http date")
+)
+
+// ContentLength returns the Content-Length header. ok is false when the
+// header is absent. Repeated identical values, as left by proxies joining
+// duplicate fields, are accepted.
+func (h Headers) ContentLength() (n int64, ok bool, err error) {
+	value := h.Get("Content-Length")
+	if value == "" {
+		return 0, false, nil
+	}
+	n = -1
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.TrimLeft(v, "0123456789") != "" {
+			return 0, true, ErrInvalidContentLength
+		}
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || (n != -1 && parsed != n) {
+			return 0, true, ErrInvalidContentLength
+		}
+		n = parsed
+	}
+	return n, true, nil
+}
+
+func (h Headers) SetContentLength(n int64) {
+	h.Override("Content-Length", strconv.FormatInt(n, 10))
+}
+
+// ContentType returns the lower cased media type of the Content-Type header
+// and its parameters. A malformed header returns an empty media type.
+func (h Headers) ContentType() (mediaType string, params map[string]string) {
+	value, params, err := ParseParameters(h.Get("Content-Type"))
+	if err != nil {
+		return "", nil
+	}
+	return strings.ToLower(value), params
+}
+
+func (h Headers) SetContentType(mediaType string, params map[string]string) {
+	h.Override("Content-Type", FormatParameters(mediaType, params))
+}
+
+// ParseHTTPDate parses an HTTP-date in the IMF-fixdate format or one of the
+// obsolete RFC 850 and asctime formats.
+func ParseHTTPDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{TimeFormat, rfc850Format, asctimeFormat} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidDate
+}
+
+// FormatHTTPDate formats t as an IMF-fixdate.
+func FormatHTTPDate(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// Time returns the HTTP-date stored in a header such as Date, Expires or
+// Last-Modified. ok is false when the header is absent.
+func (h Headers) Time(key string) (t time.Time, ok bool, err error) {
+	value := h.Get(key)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	t, err = ParseHTTPDate(value)
+	return t, true, err
+}
+
+func (h Headers) SetTime(key string, t time.Time) {
+	h.Override(key, FormatHTTPDate(t))
+}
+
+// ConnectionTokens returns the lower cased connection options listed in the
+// Connection header.
+func (h Headers) ConnectionTokens() map[string]bool {
+	tokens := map[string]bool{}
+	for _, token := range SplitList(h.Get("Connection")) {
+		tokens[strings.ToLower(token)] = true
+	}
+	return tokens
+}
+
+// HasConnectionToken reports whether the Connection header lists token.
+func (h Headers) HasConnectionToken(token string) bool {
+	return h.ConnectionTokens()[strings.ToLower(token)]
+}
+
+// CacheControl holds Cache-Control directives by lower cased name. Directives
+// without an argument map to the empty string.
+type CacheControl map[string]string
+
+// Has reports whether the directive is present.
+func (cc CacheControl) Has(directive string) bool {
+	_, ok := cc[strings.ToLower(directive)]
+	return ok
+}
+
+// Seconds returns the delta-seconds argument of a directive such as max-age.
+func (cc CacheControl) Seconds(directive string) (time.Duration, bool) {
+	value, ok := cc[strings.ToLower(directive)]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// String formats the directives in sorted order.
+func (cc CacheControl) String() string {
+	names := make([]string, 0, len(cc))
+	for name := range cc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if value := cc[name]; value != "" {
+			names[i] = name + "=" + QuoteIfNeeded(value)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// CacheControl parses the Cache-Control header.
+func (h Headers) CacheControl() CacheControl {
+	cc := CacheControl{}
+	for _, directive := range SplitList(h.Get("Cache-Control")) {
+		name, value, _ := strings.Cut(directive, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if unquoted, err := Unquote(value); err == nil {
+			value = unquoted
+		}
+		if name != "" {
+			cc[name] = value
+		}
+	}
+	return cc
+}
+
+func (h Headers) SetCacheControl(cc CacheControl) {
+	h.Override("Cache-Control", cc.String())
+}
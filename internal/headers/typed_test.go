@@ -0,0 +1,110 @@
+package headers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentLength(t *testing.T) {
+	// Test: Absent
+	h := NewHeaders()
+	_, ok, err := h.ContentLength()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Test: Valid and repeated identical values
+	h.Override("Content-Length", "42")
+	n, ok, err := h.ContentLength()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), n)
+	h.Override("Content-Length", "42, 42")
+	n, _, err = h.ContentLength()
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+
+	// Test: Invalid values
+	for _, value := range []string{"-1", "+5", "1.0", "42, 43", "abc", "99999999999999999999"} {
+		h.Override("Content-Length", value)
+		_, ok, err = h.ContentLength()
+		assert.True(t, ok)
+		assert.ErrorIs(t, err, ErrInvalidContentLength, value)
+	}
+
+	// Test: Set
+	h.SetContentLength(7)
+	assert.Equal(t, "7", h.Get("Content-Length"))
+}
+
+func TestContentType(t *testing.T) {
+	h := NewHeaders()
+	h.Set("Content-Type", `Text/HTML; charset="UTF-8"`)
+	mediaType, params := h.ContentType()
+	assert.Equal(t, "text/html", mediaType)
+	assert.Equal(t, map[string]string{"charset": "UTF-8"}, params)
+
+	h.SetContentType("application/json", map[string]string{"charset": "utf-8"})
+	assert.Equal(t, "application/json; charset=utf-8", h.Get("Content-Type"))
+
+	h.Override("Content-Type", "text/plain; charset")
+	mediaType, _ = h.ContentType()
+	assert.Equal(t, "", mediaType)
+}
+
+func TestHTTPDate(t *testing.T) {
+	want := time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC)
+
+	// Test: All three formats
+	for _, value := range []string{
+		"Sun, 06 Nov 1994 08:49:37 GMT",
+		"Sunday, 06-Nov-94 08:49:37 GMT",
+		"Sun Nov  6 08:49:37 1994",
+	} {
+		got, err := ParseHTTPDate(value)
+		require.NoError(t, err, value)
+		assert.True(t, want.Equal(got), value)
+	}
+
+	// Test: Invalid
+	_, err := ParseHTTPDate("yesterday")
+	assert.ErrorIs(t, err, ErrInvalidDate)
+
+	// Test: Header round trip
+	h := NewHeaders()
+	h.SetTime("Date", want.In(time.FixedZone("X", 3600)))
+	assert.Equal(t, "Sun, 06 Nov 1994 08:49:37 GMT", h.Get("Date"))
+	got, ok, err := h.Time("Date")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, want.Equal(got))
+	_, ok, _ = h.Time("Expires")
+	assert.False(t, ok)
+}
+
+func TestConnectionTokens(t *testing.T) {
+	h := NewHeaders()
+	h.Set("Connection", "Keep-Alive, Upgrade")
+	assert.Equal(t, map[string]bool{"keep-alive": true, "upgrade": true}, h.ConnectionTokens())
+	assert.True(t, h.HasConnectionToken("upgrade"))
+	assert.False(t, h.HasConnectionToken("close"))
+}
+
+func TestCacheControl(t *testing.T) {
+	h := NewHeaders()
+	h.Set("Cache-Control", `No-Cache, max-age=60, private="Set-Cookie"`)
+	cc := h.CacheControl()
+	assert.True(t, cc.Has("no-cache"))
+	assert.False(t, cc.Has("no-store"))
+	assert.Equal(t, "Set-Cookie", cc["private"])
+	age, ok := cc.Seconds("max-age")
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, age)
+	_, ok = cc.Seconds("no-cache")
+	assert.False(t, ok)
+
+	h.SetCacheControl(CacheControl{"public": "", "max-age": "3600", "community": "a b"})
+	assert.Equal(t, `community="a b", max-age=3600, public`, h.Get("Cache-Control"))
+}
@@ -43,3 +43,8 @@ func checkKey(str string) bool {
 	}
 	return true
 }
+
+// ValidFieldName reports whether name is a valid header field name token.
+func ValidFieldName(name string) bool {
+	return name != "" && checkKey(name)
+}
@@ -0,0 +1,167 @@
+package hpack
+
+import "http/internal/headers"
+
+// Decoder decodes the header blocks of one direction of a connection.
+type Decoder struct {
+	table dynamicTable
+	// maxTableSize is the largest table size the peer may switch to, our
+	// SETTINGS_HEADER_TABLE_SIZE in HTTP/2.
+	maxTableSize uint32
+	// maxStringLength bounds every name and value.
+	maxStringLength int
+}
+
+// NewDecoder returns a decoder whose dynamic table starts at, and may be
+// resized up to, maxTableSize bytes.
+func NewDecoder(maxTableSize uint32, maxStringLength int) *Decoder {
+	return &Decoder{
+		table:           dynamicTable{maxSize: maxTableSize},
+		maxTableSize:    maxTableSize,
+		maxStringLength: maxStringLength,
+	}
+}
+
+// Decode decodes a complete header block into headers. Repeated fields are
+// combined, cookie crumbs with "; " (RFC 9113 section 8.2.3) and the others
+// with ", ".
+func (d *Decoder) Decode(block []byte) (headers.Headers, error) {
+	fields, err := d.DecodeFields(block)
+	if err != nil {
+		return nil, err
+	}
+	h := headers.NewHeaders()
+	for _, f := range fields {
+		if v, ok := h[f.Name]; ok && f.Name == "cookie" {
+			h.Override(f.Name, v+"; "+f.Value)
+			continue
+		}
+		h.Set(f.Name, f.Value)
+	}
+	return h, nil
+}
+
+// DecodeFields decodes a complete header block, keeping the order and the
+// sensitivity of its fields. An error leaves the decoder out of sync with
+// the peer, so the connection cannot be used any further.
+func (d *Decoder) DecodeFields(block []byte) ([]HeaderField, error) {
+	var fields []HeaderField
+	for len(block) > 0 {
+		var err error
+		b := block[0]
+		switch {
+		case b&0x80 != 0:
+			// indexed field
+			var i uint64
+			i, block, err = readInt(block, 7)
+			if err != nil {
+				return nil, err
+			}
+			f, ok := d.table.field(i)
+			if !ok {
+				return nil, ErrInvalidIndex
+			}
+			fields = append(fields, f)
+		case b&0xc0 == 0x40:
+			// literal with incremental indexing
+			var f HeaderField
+			f, block, err = d.readLiteral(block, 6)
+			if err != nil {
+				return nil, err
+			}
+			d.table.add(f)
+			fields = append(fields, f)
+		case b&0xe0 == 0x20:
+			// dynamic table size update, only allowed before the first field
+			var n uint64
+			n, block, err = readInt(block, 5)
+			if err != nil {
+				return nil, err
+			}
+			if len(fields) > 0 || n > uint64(d.maxTableSize) {
+				return nil, ErrInvalidSizeUpdate
+			}
+			d.table.setMaxSize(uint32(n))
+		default:
+			// literal without indexing (0000) or never indexed (0001)
+			var f HeaderField
+			f, block, err = d.readLiteral(block, 4)
+			if err != nil {
+				return nil, err
+			}
+			f.Sensitive = b&0x10 != 0
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// readLiteral reads a literal field whose name index has prefixBits bits.
+func (d *Decoder) readLiteral(p []byte, prefixBits uint8) (HeaderField, []byte, error) {
+	var f HeaderField
+	i, p, err := readInt(p, prefixBits)
+	if err != nil {
+		return f, nil, err
+	}
+	if i == 0 {
+		f.Name, p, err = d.readString(p)
+		if err != nil {
+			return f, nil, err
+		}
+	} else {
+		indexed, ok := d.table.field(i)
+		if !ok {
+			return f, nil, ErrInvalidIndex
+		}
+		f.Name = indexed.Name
+	}
+	f.Value, p, err = d.readString(p)
+	return f, p, err
+}
+
+func (d *Decoder) readString(p []byte) (string, []byte, error) {
+	if len(p) == 0 {
+		return "", nil, ErrTruncated
+	}
+	huffman := p[0]&0x80 != 0
+	n, p, err := readInt(p, 7)
+	if err != nil {
+		return "", nil, err
+	}
+	if n > uint64(len(p)) {
+		return "", nil, ErrTruncated
+	}
+	if !huffman {
+		if n > uint64(d.maxStringLength) {
+			return "", nil, ErrStringTooLong
+		}
+		return string(p[:n]), p[n:], nil
+	}
+	s, err := huffmanDecode(p[:n], d.maxStringLength)
+	return s, p[n:], err
+}
+
+// readInt reads an integer with an N-bit prefix (RFC 7541 section 5.1).
+func readInt(p []byte, prefixBits uint8) (uint64, []byte, error) {
+	if len(p) == 0 {
+		return 0, nil, ErrTruncated
+	}
+	max := uint64(1)<<prefixBits - 1
+	n := uint64(p[0]) & max
+	p = p[1:]
+	if n < max {
+		return n, p, nil
+	}
+	for shift := uint(0); len(p) > 0; shift += 7 {
+		if shift > 28 {
+			return 0, nil, ErrIntegerOverflow
+		}
+		b := p[0]
+		p = p[1:]
+		n += uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return n, p, nil
+		}
+	}
+	return 0, nil, ErrTruncated
+}
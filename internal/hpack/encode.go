@@ -0,0 +1,132 @@
+package hpack
+
+import (
+	"sort"
+	"strings"
+
+	"http/internal/headers"
+)
+
+// Encoder encodes the header blocks of one direction of a connection.
+type Encoder struct {
+	table dynamicTable
+	// limit is the size the decoder starts with, and the most the encoder
+	// ever uses.
+	limit uint32
+	// minSize is the smallest table size since the last header block, to be
+	// announced with the current size when they differ.
+	minSize       uint32
+	pendingUpdate bool
+	noHuffman     bool
+}
+
+// NewEncoder returns an encoder whose dynamic table starts at, and never
+// grows beyond, maxTableSize bytes. It must match the decoder's initial
+// size, DefaultTableSize in HTTP/2.
+func NewEncoder(maxTableSize uint32) *Encoder {
+	return &Encoder{
+		table:   dynamicTable{maxSize: maxTableSize},
+		limit:   maxTableSize,
+		minSize: maxTableSize,
+	}
+}
+
+// SetMaxTableSize applies the largest table size the decoder accepts, the
+// peer's SETTINGS_HEADER_TABLE_SIZE in HTTP/2. The change is announced at
+// the start of the next header block.
+func (e *Encoder) SetMaxTableSize(n uint32) {
+	n = min(n, e.limit)
+	if n == e.table.maxSize {
+		return
+	}
+	e.table.setMaxSize(n)
+	e.minSize = min(e.minSize, n)
+	e.pendingUpdate = true
+}
+
+// SetHuffman sets whether strings are Huffman coded when that does not make
+// them longer, which is the default.
+func (e *Encoder) SetHuffman(enabled bool) {
+	e.noHuffman = !enabled
+}
+
+// Encode appends the header block for h to dst. Pseudo-header fields come
+// first and the rest follow in sorted order.
+func (e *Encoder) Encode(dst []byte, h headers.Headers) []byte {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := strings.HasPrefix(keys[i], ":"), strings.HasPrefix(keys[j], ":")
+		if pi != pj {
+			return pi
+		}
+		return keys[i] < keys[j]
+	})
+	fields := make([]HeaderField, len(keys))
+	for i, k := range keys {
+		fields[i] = HeaderField{Name: k, Value: h[k]}
+	}
+	return e.EncodeFields(dst, fields)
+}
+
+// EncodeFields appends the header block for fields to dst. Fields are
+// indexed when the dynamic table can hold them, unless they are sensitive.
+func (e *Encoder) EncodeFields(dst []byte, fields []HeaderField) []byte {
+	if e.pendingUpdate {
+		if e.minSize < e.table.maxSize {
+			dst = appendInt(dst, 5, 0x20, uint64(e.minSize))
+		}
+		dst = appendInt(dst, 5, 0x20, uint64(e.table.maxSize))
+		e.pendingUpdate = false
+		e.minSize = e.table.maxSize
+	}
+	for _, f := range fields {
+		sensitive := f.Sensitive || sensitiveFields[f.Name]
+		index, valueMatch := e.table.search(f)
+		switch {
+		case valueMatch && !sensitive:
+			dst = appendInt(dst, 7, 0x80, index)
+			continue
+		case sensitive:
+			dst = appendInt(dst, 4, 0x10, index)
+		case f.Size() > e.table.maxSize:
+			dst = appendInt(dst, 4, 0x00, index)
+		default:
+			dst = appendInt(dst, 6, 0x40, index)
+			e.table.add(f)
+		}
+		if index == 0 {
+			dst = e.appendString(dst, f.Name)
+		}
+		dst = e.appendString(dst, f.Value)
+	}
+	return dst
+}
+
+// appendInt appends n with an N-bit prefix, the first byte's other bits
+// being flags.
+func appendInt(dst []byte, prefixBits uint8, flags byte, n uint64) []byte {
+	max := uint64(1)<<prefixBits - 1
+	if n < max {
+		return append(dst, flags|byte(n))
+	}
+	dst = append(dst, flags|byte(max))
+	n -= max
+	for n >= 0x80 {
+		dst = append(dst, byte(n)|0x80)
+		n >>= 7
+	}
+	return append(dst, byte(n))
+}
+
+// appendString appends s, Huffman coded unless that is longer.
+func (e *Encoder) appendString(dst []byte, s string) []byte {
+	if n := huffmanLength(s); !e.noHuffman && n <= len(s) {
+		dst = appendInt(dst, 7, 0x80, uint64(n))
+		return appendHuffman(dst, s)
+	}
+	dst = appendInt(dst, 7, 0, uint64(len(s)))
+	return append(dst, s...)
+}
@@ -0,0 +1,197 @@
+// Package hpack implements HPACK, the header compression format of HTTP/2
+// (RFC 7541).
+package hpack
+
+import "errors"
+
+// DefaultTableSize is the initial size of both dynamic tables of a
+// connection (RFC 7541 section 4.2).
+const DefaultTableSize = 4096
+
+var (
+	ErrTruncated         = errors.New("hpack: truncated header block")
+	ErrInvalidIndex      = errors.New("hpack: invalid table index")
+	ErrIntegerOverflow   = errors.New("hpack: integer overflow")
+	ErrStringTooLong     = errors.New("hpack: string too long")
+	ErrInvalidHuffman    = errors.New("hpack: invalid Huffman data")
+	ErrInvalidSizeUpdate = errors.New("hpack: invalid dynamic table size update")
+)
+
+// HeaderField is one field of a header block. Sensitive fields are sent as
+// never-indexed literals, so that neither we nor intermediaries put them in
+// a compression context.
+type HeaderField struct {
+	Name      string
+	Value     string
+	Sensitive bool
+}
+
+// Size is the field's size in the dynamic table (RFC 7541 section 4.1).
+func (f HeaderField) Size() uint32 {
+	return uint32(len(f.Name) + len(f.Value) + 32)
+}
+
+// sensitiveFields are always encoded as never-indexed literals. Credentials
+// are short and guessable enough for compression to leak them (RFC 7541
+// section 7.1).
+var sensitiveFields = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+}
+
+// staticTable is the HPACK static table from RFC 7541 appendix A. Index 1 is
+// staticTable[0].
+var staticTable = [...]HeaderField{
+	{Name: ":authority"},
+	{Name: ":method", Value: "GET"},
+	{Name: ":method", Value: "POST"},
+	{Name: ":path", Value: "/"},
+	{Name: ":path", Value: "/index.html"},
+	{Name: ":scheme", Value: "http"},
+	{Name: ":scheme", Value: "https"},
+	{Name: ":status", Value: "200"},
+	{Name: ":status", Value: "204"},
+	{Name: ":status", Value: "206"},
+	{Name: ":status", Value: "304"},
+	{Name: ":status", Value: "400"},
+	{Name: ":status", Value: "404"},
+	{Name: ":status", Value: "500"},
+	{Name: "accept-charset"},
+	{Name: "accept-encoding", Value: "gzip, deflate"},
+	{Name: "accept-language"},
+	{Name: "accept-ranges"},
+	{Name: "accept"},
+	{Name: "access-control-allow-origin"},
+	{Name: "age"},
+	{Name: "allow"},
+	{Name: "authorization"},
+	{Name: "cache-control"},
+	{Name: "content-disposition"},
+	{Name: "content-encoding"},
+	{Name: "content-language"},
+	{Name: "content-length"},
+	{Name: "content-location"},
+	{Name: "content-range"},
+	{Name: "content-type"},
+	{Name: "cookie"},
+	{Name: "date"},
+	{Name: "etag"},
+	{Name: "expect"},
+	{Name: "expires"},
+	{Name: "from"},
+	{Name: "host"},
+	{Name: "if-match"},
+	{Name: "if-modified-since"},
+	{Name: "if-none-match"},
+	{Name: "if-range"},
+	{Name: "if-unmodified-since"},
+	{Name: "last-modified"},
+	{Name: "link"},
+	{Name: "location"},
+	{Name: "max-forwards"},
+	{Name: "proxy-authenticate"},
+	{Name: "proxy-authorization"},
+	{Name: "range"},
+	{Name: "referer"},
+	{Name: "refresh"},
+	{Name: "retry-after"},
+	{Name: "server"},
+	{Name: "set-cookie"},
+	{Name: "strict-transport-security"},
+	{Name: "transfer-encoding"},
+	{Name: "user-agent"},
+	{Name: "vary"},
+	{Name: "via"},
+	{Name: "www-authenticate"},
+}
+
+// staticExact and staticName map fields and names to their lowest static
+// table index.
+var (
+	staticExact = map[[2]string]uint64{}
+	staticName  = map[string]uint64{}
+)
+
+func init() {
+	for i, f := range staticTable {
+		index := uint64(i + 1)
+		if _, ok := staticExact[[2]string{f.Name, f.Value}]; !ok {
+			staticExact[[2]string{f.Name, f.Value}] = index
+		}
+		if _, ok := staticName[f.Name]; !ok {
+			staticName[f.Name] = index
+		}
+	}
+}
+
+// dynamicTable is an HPACK dynamic table. Entries are kept oldest first;
+// index 1 is the newest.
+type dynamicTable struct {
+	fields  []HeaderField
+	size    uint32
+	maxSize uint32
+}
+
+func (t *dynamicTable) add(f HeaderField) {
+	t.evict(f.Size())
+	if f.Size() > t.maxSize {
+		return
+	}
+	f.Sensitive = false
+	t.fields = append(t.fields, f)
+	t.size += f.Size()
+}
+
+func (t *dynamicTable) setMaxSize(n uint32) {
+	t.maxSize = n
+	t.evict(0)
+}
+
+// evict drops the oldest entries until need more bytes fit.
+func (t *dynamicTable) evict(need uint32) {
+	n := 0
+	for n < len(t.fields) && t.size+need > t.maxSize {
+		t.size -= t.fields[n].Size()
+		n++
+	}
+	t.fields = append(t.fields[:0], t.fields[n:]...)
+}
+
+// field returns the entry at index i of the combined static and dynamic
+// index space.
+func (t *dynamicTable) field(i uint64) (HeaderField, bool) {
+	if i == 0 {
+		return HeaderField{}, false
+	}
+	if i <= uint64(len(staticTable)) {
+		return staticTable[i-1], true
+	}
+	i -= uint64(len(staticTable))
+	if i > uint64(len(t.fields)) {
+		return HeaderField{}, false
+	}
+	return t.fields[len(t.fields)-int(i)], true
+}
+
+// search returns the index of an entry matching f, preferring entries that
+// also match the value. It returns 0 when even the name is unknown.
+func (t *dynamicTable) search(f HeaderField) (index uint64, valueMatch bool) {
+	if i, ok := staticExact[[2]string{f.Name, f.Value}]; ok {
+		return i, true
+	}
+	nameIndex := staticName[f.Name]
+	for i := len(t.fields) - 1; i >= 0; i-- {
+		if t.fields[i].Name != f.Name {
+			continue
+		}
+		index := uint64(len(staticTable) + len(t.fields) - i)
+		if t.fields[i].Value == f.Value {
+			return index, true
+		}
+		if nameIndex == 0 {
+			nameIndex = index
+		}
+	}
+	return nameIndex, false
+}
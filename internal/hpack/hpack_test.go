@@ -0,0 +1,281 @@
+package hpack
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/headers"
+)
+
+// fields builds header fields from name and value pairs.
+func fields(pairs ...string) []HeaderField {
+	var fs []HeaderField
+	for i := 0; i < len(pairs); i += 2 {
+		fs = append(fs, HeaderField{Name: pairs[i], Value: pairs[i+1]})
+	}
+	return fs
+}
+
+// exchange is one header block of an RFC 7541 appendix C example, with the
+// dynamic table once it has been processed.
+type exchange struct {
+	fields []HeaderField
+	block  string
+	table  []HeaderField
+	size   uint32
+}
+
+// runExamples encodes and decodes a sequence of header blocks sharing one
+// compression context in each direction.
+func runExamples(t *testing.T, tableSize uint32, huffman bool, examples []exchange) {
+	t.Helper()
+	enc := NewEncoder(tableSize)
+	enc.SetHuffman(huffman)
+	dec := NewDecoder(tableSize, 1<<10)
+	for i, ex := range examples {
+		block := enc.EncodeFields(nil, ex.fields)
+		assert.Equal(t, ex.block, hex.EncodeToString(block), "block %d", i+1)
+		raw, err := hex.DecodeString(ex.block)
+		require.NoError(t, err)
+		decoded, err := dec.DecodeFields(raw)
+		require.NoError(t, err)
+		assert.Equal(t, ex.fields, decoded, "block %d", i+1)
+		for _, table := range []dynamicTable{enc.table, dec.table} {
+			var newestFirst []HeaderField
+			for j := len(table.fields) - 1; j >= 0; j-- {
+				newestFirst = append(newestFirst, table.fields[j])
+			}
+			assert.Equal(t, ex.table, newestFirst, "block %d", i+1)
+			assert.Equal(t, ex.size, table.size, "block %d", i+1)
+		}
+	}
+}
+
+func TestHuffman(t *testing.T) {
+	// Test: RFC 7541 appendix C.4.1
+	encoded := appendHuffman(nil, "www.example.com")
+	assert.Equal(t, "f1e3c2e5f23a6ba0ab90f4ff", hex.EncodeToString(encoded))
+	assert.Equal(t, len(encoded), huffmanLength("www.example.com"))
+
+	// Test: Round trip of every byte value
+	var all strings.Builder
+	for i := 0; i < 256; i++ {
+		all.WriteByte(byte(i))
+	}
+	decoded, err := huffmanDecode(appendHuffman(nil, all.String()), 256)
+	require.NoError(t, err)
+	assert.Equal(t, all.String(), decoded)
+
+	// Test: Padding that is not a prefix of EOS
+	_, err = huffmanDecode([]byte{0xf1, 0xe0}, 10)
+	require.ErrorIs(t, err, ErrInvalidHuffman)
+
+	// Test: Padding of 8 bits or more
+	_, err = huffmanDecode([]byte{0x1f, 0xff}, 10)
+	require.ErrorIs(t, err, ErrInvalidHuffman)
+
+	// Test: Output limit
+	_, err = huffmanDecode(encoded, 5)
+	require.ErrorIs(t, err, ErrStringTooLong)
+}
+
+func TestLiteralExamples(t *testing.T) {
+	// Test: RFC 7541 appendix C.2.1, literal with indexing
+	runExamples(t, DefaultTableSize, false, []exchange{{
+		fields: fields("custom-key", "custom-header"),
+		block:  "400a637573746f6d2d6b65790d637573746f6d2d686561646572",
+		table:  fields("custom-key", "custom-header"),
+		size:   55,
+	}})
+
+	// Test: RFC 7541 appendix C.2.2, literal without indexing
+	dec := NewDecoder(DefaultTableSize, 1<<10)
+	decoded, err := dec.DecodeFields([]byte("\x04\x0c/sample/path"))
+	require.NoError(t, err)
+	assert.Equal(t, fields(":path", "/sample/path"), decoded)
+	assert.Empty(t, dec.table.fields)
+
+	// Test: RFC 7541 appendix C.2.3, never-indexed literal
+	secret := []HeaderField{{Name: "password", Value: "secret", Sensitive: true}}
+	runExamples(t, DefaultTableSize, false, []exchange{{
+		fields: secret,
+		block:  "100870617373776f726406736563726574",
+	}})
+
+	// Test: RFC 7541 appendix C.2.4, indexed field
+	runExamples(t, DefaultTableSize, false, []exchange{{
+		fields: fields(":method", "GET"),
+		block:  "82",
+	}})
+}
+
+func TestRequestExamples(t *testing.T) {
+	first := fields(":method", "GET", ":scheme", "http", ":path", "/", ":authority", "www.example.com")
+	second := append(fields(":method", "GET", ":scheme", "http", ":path", "/", ":authority", "www.example.com"),
+		fields("cache-control", "no-cache")...)
+	third := fields(":method", "GET", ":scheme", "https", ":path", "/index.html",
+		":authority", "www.example.com", "custom-key", "custom-value")
+	tables := [][]HeaderField{
+		fields(":authority", "www.example.com"),
+		fields("cache-control", "no-cache", ":authority", "www.example.com"),
+		fields("custom-key", "custom-value", "cache-control", "no-cache", ":authority", "www.example.com"),
+	}
+
+	// Test: RFC 7541 appendix C.3, requests without Huffman coding
+	runExamples(t, DefaultTableSize, false, []exchange{
+		{first, "828684410f7777772e6578616d706c652e636f6d", tables[0], 57},
+		{second, "828684be58086e6f2d6361636865", tables[1], 110},
+		{third, "828785bf400a637573746f6d2d6b65790c637573746f6d2d76616c7565", tables[2], 164},
+	})
+
+	// Test: RFC 7541 appendix C.4, requests with Huffman coding
+	runExamples(t, DefaultTableSize, true, []exchange{
+		{first, "828684418cf1e3c2e5f23a6ba0ab90f4ff", tables[0], 57},
+		{second, "828684be5886a8eb10649cbf", tables[1], 110},
+		{third, "828785bf408825a849e95ba97d7f8925a849e95bb8e8b4bf", tables[2], 164},
+	})
+}
+
+func TestResponseExamples(t *testing.T) {
+	const (
+		date1    = "Mon, 21 Oct 2013 20:13:21 GMT"
+		date2    = "Mon, 21 Oct 2013 20:13:22 GMT"
+		location = "https://www.example.com"
+		cookie   = "foo=ASDJKHQKBZXOQWEOPIUAXQWEOIU; max-age=3600; version=1"
+	)
+	first := fields(":status", "302", "cache-control", "private", "date", date1, "location", location)
+	second := fields(":status", "307", "cache-control", "private", "date", date1, "location", location)
+	third := fields(":status", "200", "cache-control", "private", "date", date2, "location", location,
+		"content-encoding", "gzip", "set-cookie", cookie)
+	tables := [][]HeaderField{
+		fields("location", location, "date", date1, "cache-control", "private", ":status", "302"),
+		fields(":status", "307", "location", location, "date", date1, "cache-control", "private"),
+		fields("set-cookie", cookie, "content-encoding", "gzip", "date", date2),
+	}
+
+	// Test: RFC 7541 appendix C.5, responses without Huffman coding and
+	// with evictions from a 256 byte table
+	runExamples(t, 256, false, []exchange{
+		{first, "4803333032580770726976617465611d4d6f6e2c203231204f637420323031332032303a31333a323120474d54" +
+			"6e1768747470733a2f2f7777772e6578616d706c652e636f6d", tables[0], 222},
+		{second, "4803333037c1c0bf", tables[1], 222},
+		{third, "88c1611d4d6f6e2c203231204f637420323031332032303a31333a323220474d54c05a04677a6970" +
+			"7738666f6f3d4153444a4b48514b425a584f5157454f50495541585157454f49553b206d61782d6167653d" +
+			"333630303b2076657273696f6e3d31", tables[2], 215},
+	})
+
+	// Test: RFC 7541 appendix C.6, responses with Huffman coding
+	runExamples(t, 256, true, []exchange{
+		{first, "488264025885aec3771a4b6196d07abe941054d444a8200595040b8166e082a62d1bff" +
+			"6e919d29ad171863c78f0b97c8e9ae82ae43d3", tables[0], 222},
+		{second, "4883640effc1c0bf", tables[1], 222},
+		{third, "88c16196d07abe941054d444a8200595040b8166e084a62d1bffc05a839bd9ab77ad94e7821dd7f2e6c7b3" +
+			"35dfdfcd5b3960d5af27087f3672c1ab270fb5291f9587316065c003ed4ee5b1063d5007", tables[2], 215},
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	enc := NewEncoder(DefaultTableSize)
+	dec := NewDecoder(DefaultTableSize, 1<<10)
+	fs := []HeaderField{
+		{Name: ":status", Value: "200"},
+		{Name: "content-type", Value: "text/html"},
+		{Name: "x-custom", Value: "value"},
+		{Name: "set-cookie", Value: "id=secret", Sensitive: true},
+	}
+
+	// Test: Second block is smaller thanks to the dynamic table
+	first := enc.EncodeFields(nil, fs)
+	second := enc.EncodeFields(nil, fs)
+	assert.Less(t, len(second), len(first))
+	for _, block := range [][]byte{first, second} {
+		decoded, err := dec.DecodeFields(block)
+		require.NoError(t, err)
+		assert.Equal(t, fs, decoded)
+	}
+	assert.Len(t, dec.table.fields, 2)
+
+	// Test: Table size update from the peer's settings
+	enc.SetMaxTableSize(0)
+	block := enc.EncodeFields(nil, fs[:1])
+	assert.Equal(t, []byte{0x20, 0x88}, block)
+	decoded, err := dec.DecodeFields(block)
+	require.NoError(t, err)
+	assert.Equal(t, fs[:1], decoded)
+	assert.Empty(t, dec.table.fields)
+
+	// Test: Shrinking and growing again announces both sizes
+	resized := NewEncoder(DefaultTableSize)
+	resized.SetMaxTableSize(100)
+	resized.SetMaxTableSize(50)
+	resized.SetMaxTableSize(200)
+	assert.Equal(t, []byte{0x3f, 0x13, 0x3f, 0xa9, 0x01, 0x88}, resized.EncodeFields(nil, fs[:1]))
+
+	// Test: The encoder stays within its initial size
+	enc.SetMaxTableSize(1 << 20)
+	assert.Equal(t, uint32(DefaultTableSize), enc.table.maxSize)
+
+	// Test: Size update after a field
+	_, err = dec.DecodeFields([]byte{0x88, 0x20})
+	require.ErrorIs(t, err, ErrInvalidSizeUpdate)
+
+	// Test: Size update beyond the decoder's limit
+	_, err = dec.DecodeFields([]byte{0x3f, 0xe2, 0x1f})
+	require.ErrorIs(t, err, ErrInvalidSizeUpdate)
+
+	// Test: Index past the dynamic table
+	_, err = dec.DecodeFields([]byte{0xbf})
+	require.ErrorIs(t, err, ErrInvalidIndex)
+
+	// Test: Truncated literal
+	_, err = dec.DecodeFields([]byte{0x40, 0x05, 'a'})
+	require.ErrorIs(t, err, ErrTruncated)
+
+	// Test: Integer overflow
+	_, err = dec.DecodeFields([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	require.ErrorIs(t, err, ErrIntegerOverflow)
+}
+
+func TestHeaders(t *testing.T) {
+	enc := NewEncoder(DefaultTableSize)
+	dec := NewDecoder(DefaultTableSize, 1<<10)
+	h := headers.Headers{
+		"x-b":           "2",
+		"authorization": "Bearer token",
+		":path":         "/",
+		":method":       "GET",
+		"cookie":        "a=1",
+		"x-a":           "1",
+	}
+
+	// Test: Pseudo-header fields first, credentials never indexed
+	block := enc.Encode(nil, h)
+	decoded, err := dec.DecodeFields(block)
+	require.NoError(t, err)
+	assert.Equal(t, []HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":path", Value: "/"},
+		{Name: "authorization", Value: "Bearer token", Sensitive: true},
+		{Name: "cookie", Value: "a=1", Sensitive: true},
+		{Name: "x-a", Value: "1"},
+		{Name: "x-b", Value: "2"},
+	}, decoded)
+	assert.Equal(t, fields("x-b", "2", "x-a", "1"), []HeaderField{enc.table.fields[1], enc.table.fields[0]})
+
+	// Test: The second block indexes all but the credentials
+	block = enc.Encode(nil, h)
+	assert.Equal(t, byte(0x1f), block[2])
+	decodedHeaders, err := dec.Decode(block)
+	require.NoError(t, err)
+	assert.Equal(t, h, decodedHeaders)
+
+	// Test: Repeated fields are combined, cookie crumbs with semicolons
+	decodedHeaders, err = dec.Decode(enc.EncodeFields(nil,
+		fields("cookie", "a=1", "cookie", "b=2", "accept", "text/html", "accept", "*/*")))
+	require.NoError(t, err)
+	assert.Equal(t, headers.Headers{"cookie": "a=1; b=2", "accept": "text/html, */*"}, decodedHeaders)
+}
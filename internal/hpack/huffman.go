@@ -0,0 +1,95 @@
+package hpack
+
+import "sync"
+
+// huffmanNode is a node of the Huffman decoding tree. Leaves have no
+// children.
+type huffmanNode struct {
+	children [2]*huffmanNode
+	sym      byte
+}
+
+var (
+	huffmanRootOnce sync.Once
+	huffmanRoot     *huffmanNode
+)
+
+func buildHuffmanTree() {
+	huffmanRoot = &huffmanNode{}
+	for sym, code := range huffmanCodes {
+		n := huffmanRoot
+		for i := int(huffmanCodeLen[sym]) - 1; i >= 0; i-- {
+			bit := code >> uint(i) & 1
+			if n.children[bit] == nil {
+				n.children[bit] = &huffmanNode{}
+			}
+			n = n.children[bit]
+		}
+		n.sym = byte(sym)
+	}
+}
+
+// huffmanDecode decodes a Huffman coded string of at most maxLen bytes. The
+// padding must be a prefix of the EOS code shorter than 8 bits, and EOS
+// itself may not appear (RFC 7541 section 5.2).
+func huffmanDecode(p []byte, maxLen int) (string, error) {
+	huffmanRootOnce.Do(buildHuffmanTree)
+	out := make([]byte, 0, len(p)*8/5)
+	n := huffmanRoot
+	// pending counts the bits read since the last symbol; ones tells
+	// whether they were all ones.
+	pending, ones := 0, true
+	for _, b := range p {
+		for i := 7; i >= 0; i-- {
+			bit := b >> uint(i) & 1
+			n = n.children[bit]
+			if n == nil {
+				// only EOS, which is 30 bits long, leads outside the tree
+				return "", ErrInvalidHuffman
+			}
+			pending++
+			ones = ones && bit == 1
+			if n.children[0] != nil {
+				continue
+			}
+			if len(out) == maxLen {
+				return "", ErrStringTooLong
+			}
+			out = append(out, n.sym)
+			n, pending, ones = huffmanRoot, 0, true
+		}
+	}
+	if pending > 7 || !ones {
+		return "", ErrInvalidHuffman
+	}
+	return string(out), nil
+}
+
+// huffmanLength returns the length of s once Huffman coded.
+func huffmanLength(s string) int {
+	bits := 0
+	for i := 0; i < len(s); i++ {
+		bits += int(huffmanCodeLen[s[i]])
+	}
+	return (bits + 7) / 8
+}
+
+// appendHuffman appends the Huffman coding of s, padded with the most
+// significant bits of EOS.
+func appendHuffman(dst []byte, s string) []byte {
+	var acc uint64
+	bits := uint(0)
+	for i := 0; i < len(s); i++ {
+		acc = acc<<huffmanCodeLen[s[i]] | uint64(huffmanCodes[s[i]])
+		bits += uint(huffmanCodeLen[s[i]])
+		for bits >= 8 {
+			bits -= 8
+			dst = append(dst, byte(acc>>bits))
+		}
+	}
+	if bits > 0 {
+		acc = acc<<(8-bits) | 0xff>>bits
+		dst = append(dst, byte(acc))
+	}
+	return dst
+}
@@ -0,0 +1,210 @@
+package http2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ClientPreface starts every HTTP/2 connection (RFC 9113 section 3.4).
+const ClientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+const (
+	frameHeaderLen = 9
+	// minMaxFrameSize is the initial SETTINGS_MAX_FRAME_SIZE and the smallest
+	// value it can take; maxMaxFrameSize is the largest.
+	minMaxFrameSize = 1 << 14
+	maxMaxFrameSize = 1<<24 - 1
+	maxWindowSize   = 1<<31 - 1
+)
+
+var ErrFrameTooLarge = errors.New("http2: frame too large")
+
+type FrameType uint8
+
+const (
+	FrameData         FrameType = 0x0
+	FrameHeaders      FrameType = 0x1
+	FramePriority     FrameType = 0x2
+	FrameRSTStream    FrameType = 0x3
+	FrameSettings     FrameType = 0x4
+	FramePushPromise  FrameType = 0x5
+	FramePing         FrameType = 0x6
+	FrameGoAway       FrameType = 0x7
+	FrameWindowUpdate FrameType = 0x8
+	FrameContinuation FrameType = 0x9
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case FrameData:
+		return "DATA"
+	case FrameHeaders:
+		return "HEADERS"
+	case FramePriority:
+		return "PRIORITY"
+	case FrameRSTStream:
+		return "RST_STREAM"
+	case FrameSettings:
+		return "SETTINGS"
+	case FramePushPromise:
+		return "PUSH_PROMISE"
+	case FramePing:
+		return "PING"
+	case FrameGoAway:
+		return "GOAWAY"
+	case FrameWindowUpdate:
+		return "WINDOW_UPDATE"
+	case FrameContinuation:
+		return "CONTINUATION"
+	default:
+		return fmt.Sprintf("UNKNOWN_FRAME_TYPE_%d", uint8(t))
+	}
+}
+
+type Flags uint8
+
+const (
+	FlagEndStream  Flags = 0x1
+	FlagAck        Flags = 0x1
+	FlagEndHeaders Flags = 0x4
+	FlagPadded     Flags = 0x8
+	FlagPriority   Flags = 0x20
+)
+
+// Has reports whether all of f2 are set in f.
+func (f Flags) Has(f2 Flags) bool {
+	return f&f2 == f2
+}
+
+// Frame is a frame with its payload still encoded.
+type Frame struct {
+	Type     FrameType
+	Flags    Flags
+	StreamID uint32
+	Payload  []byte
+}
+
+// ReadFrame reads the next frame, failing with ErrFrameTooLarge when the
+// payload is longer than maxSize. The header of the rejected frame is still
+// returned.
+func ReadFrame(r io.Reader, maxSize uint32) (Frame, error) {
+	var head [frameHeaderLen]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return Frame{}, err
+	}
+	length := uint32(head[0])<<16 | uint32(head[1])<<8 | uint32(head[2])
+	f := Frame{
+		Type:     FrameType(head[3]),
+		Flags:    Flags(head[4]),
+		StreamID: binary.BigEndian.Uint32(head[5:]) & (1<<31 - 1),
+	}
+	if length > maxSize {
+		return f, ErrFrameTooLarge
+	}
+	f.Payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.Payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+	return f, nil
+}
+
+// WriteFrame writes a frame. The payload must fit in the peer's
+// SETTINGS_MAX_FRAME_SIZE.
+func WriteFrame(w io.Writer, t FrameType, flags Flags, streamID uint32, payload []byte) error {
+	if len(payload) > maxMaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	buf := make([]byte, frameHeaderLen, frameHeaderLen+len(payload))
+	buf[0] = byte(len(payload) >> 16)
+	buf[1] = byte(len(payload) >> 8)
+	buf[2] = byte(len(payload))
+	buf[3] = byte(t)
+	buf[4] = byte(flags)
+	binary.BigEndian.PutUint32(buf[5:], streamID&(1<<31-1))
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+type SettingID uint16
+
+const (
+	SettingHeaderTableSize      SettingID = 0x1
+	SettingEnablePush           SettingID = 0x2
+	SettingMaxConcurrentStreams SettingID = 0x3
+	SettingInitialWindowSize    SettingID = 0x4
+	SettingMaxFrameSize         SettingID = 0x5
+	SettingMaxHeaderListSize    SettingID = 0x6
+)
+
+type Setting struct {
+	ID    SettingID
+	Value uint32
+}
+
+// Valid checks a setting's value against the limits of RFC 9113 section
+// 6.5.2.
+func (s Setting) Valid() error {
+	switch s.ID {
+	case SettingEnablePush:
+		if s.Value > 1 {
+			return ConnectionError{Code: ErrCodeProtocol, Reason: "invalid SETTINGS_ENABLE_PUSH"}
+		}
+	case SettingInitialWindowSize:
+		if s.Value > maxWindowSize {
+			return ConnectionError{Code: ErrCodeFlowControl, Reason: "invalid SETTINGS_INITIAL_WINDOW_SIZE"}
+		}
+	case SettingMaxFrameSize:
+		if s.Value < minMaxFrameSize || s.Value > maxMaxFrameSize {
+			return ConnectionError{Code: ErrCodeProtocol, Reason: "invalid SETTINGS_MAX_FRAME_SIZE"}
+		}
+	}
+	return nil
+}
+
+// ParseSettings decodes the payload of a SETTINGS frame.
+func ParseSettings(payload []byte) ([]Setting, error) {
+	if len(payload)%6 != 0 {
+		return nil, ConnectionError{Code: ErrCodeFrameSize, Reason: "SETTINGS length not a multiple of 6"}
+	}
+	settings := make([]Setting, 0, len(payload)/6)
+	for ; len(payload) > 0; payload = payload[6:] {
+		s := Setting{
+			ID:    SettingID(binary.BigEndian.Uint16(payload)),
+			Value: binary.BigEndian.Uint32(payload[2:]),
+		}
+		if err := s.Valid(); err != nil {
+			return nil, err
+		}
+		settings = append(settings, s)
+	}
+	return settings, nil
+}
+
+// AppendSettings appends the SETTINGS payload for settings to dst.
+func AppendSettings(dst []byte, settings ...Setting) []byte {
+	for _, s := range settings {
+		dst = binary.BigEndian.AppendUint16(dst, uint16(s.ID))
+		dst = binary.BigEndian.AppendUint32(dst, s.Value)
+	}
+	return dst
+}
+
+// removePadding strips the padding of a DATA or HEADERS frame.
+func removePadding(f Frame) ([]byte, error) {
+	if !f.Flags.Has(FlagPadded) {
+		return f.Payload, nil
+	}
+	if len(f.Payload) == 0 {
+		return nil, ConnectionError{Code: ErrCodeFrameSize, Reason: "missing pad length"}
+	}
+	padding := int(f.Payload[0])
+	if padding >= len(f.Payload) {
+		return nil, ConnectionError{Code: ErrCodeProtocol, Reason: "padding longer than payload"}
+	}
+	return f.Payload[1 : len(f.Payload)-padding], nil
+}
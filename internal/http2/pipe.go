@@ -0,0 +1,76 @@
+package http2
+
+import (
+	"bytes"
+	"sync"
+)
+
+// pipe carries a request body from the connection's read loop to the
+// handler. Flow control bounds how much it buffers.
+type pipe struct {
+	mu   sync.Mutex
+	cond sync.Cond
+	buf  bytes.Buffer
+	err  error
+	// onRead is told how many bytes the handler consumed, to return them
+	// to the client's flow control windows.
+	onRead func(n int)
+}
+
+func newPipe(onRead func(n int)) *pipe {
+	p := &pipe{onRead: onRead}
+	p.cond.L = &p.mu
+	return p
+}
+
+func (p *pipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	for p.buf.Len() == 0 && p.err == nil {
+		p.cond.Wait()
+	}
+	if p.buf.Len() == 0 {
+		err := p.err
+		p.mu.Unlock()
+		return 0, err
+	}
+	n, _ := p.buf.Read(b)
+	p.mu.Unlock()
+	p.onRead(n)
+	return n, nil
+}
+
+// write buffers data for the reader. It reports false when the pipe has
+// been closed and the data dropped.
+func (p *pipe) write(data []byte) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return false
+	}
+	p.buf.Write(data)
+	p.cond.Broadcast()
+	return true
+}
+
+// closeWithError makes reads fail with err once the buffered data has been
+// read.
+func (p *pipe) closeWithError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.cond.Broadcast()
+}
+
+// discard drops the buffered data, makes reads fail with err and returns
+// the number of bytes dropped.
+func (p *pipe) discard(err error) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := p.buf.Len()
+	p.buf.Reset()
+	p.err = err
+	p.cond.Broadcast()
+	return n
+}
@@ -0,0 +1,584 @@
+package http2
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"http/internal/hpack"
+	"http/internal/request"
+	"http/internal/response"
+)
+
+// Handler has the signature of server.Handler, which cannot be used here
+// since the server package serves HTTP/2 through this one.
+type Handler func(w *response.Writer, r *request.Request)
+
+const (
+	maxConcurrentStreams = 100
+	maxHeaderListSize    = 64 << 10
+	initialWindowSize    = 65535
+)
+
+var (
+	errConnClosed   = errors.New("http2: connection closed")
+	errStreamClosed = errors.New("http2: stream closed")
+	errStreamReset  = errors.New("http2: stream reset")
+)
+
+// serverConn is the server side of an HTTP/2 connection. The read loop in
+// serve processes every incoming frame; handlers run in a goroutine per
+// stream and write their frames directly.
+type serverConn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	handler  Handler
+	ctx      context.Context
+	cancel   context.CancelFunc
+	handlers sync.WaitGroup
+
+	// used by the read loop only
+	dec         *hpack.Decoder
+	maxStreamID uint32
+	// headerBlock collects a HEADERS frame and its CONTINUATION frames
+	headerBlock        []byte
+	headerStream       uint32
+	headerEndStream    bool
+	headerSelfDepends  bool
+	continuationStream uint32
+
+	// writeMu serializes frames and keeps header blocks in the order the
+	// encoder produced them.
+	writeMu sync.Mutex
+	bw      *bufio.Writer
+	enc     *hpack.Encoder
+
+	// mu guards the fields below and the flow control state of streams.
+	// It is never held while waiting for writeMu.
+	mu                sync.Mutex
+	cond              sync.Cond
+	streams           map[uint32]*stream
+	closed            bool
+	sendWindow        int64
+	recvWindow        int64
+	peerInitialWindow int64
+	peerMaxFrameSize  uint32
+}
+
+// ServeConn serves HTTP/2 on conn until the client goes away. The client
+// connection preface starts at buffered, followed by the rest of conn.
+// Closing conn is left to the caller.
+func ServeConn(conn net.Conn, buffered []byte, handler Handler) error {
+	return newServerConn(conn, buffered, handler).serve(nil)
+}
+
+// ServeUpgrade serves HTTP/2 on conn after a 101 response to an h2c upgrade
+// request r. The client's settings come from its HTTP2-Settings header and
+// r, whose body must have been read already, becomes stream 1.
+func ServeUpgrade(conn net.Conn, buffered []byte, settings []Setting, r *request.Request, handler Handler) error {
+	sc := newServerConn(conn, buffered, handler)
+	if err := sc.applySettings(settings); err != nil {
+		return err
+	}
+	return sc.serve(r)
+}
+
+func newServerConn(conn net.Conn, buffered []byte, handler Handler) *serverConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	sc := &serverConn{
+		conn:              conn,
+		br:                bufio.NewReader(io.MultiReader(&byteReader{buffered}, conn)),
+		handler:           handler,
+		ctx:               ctx,
+		cancel:            cancel,
+		dec:               hpack.NewDecoder(hpack.DefaultTableSize, maxHeaderListSize),
+		bw:                bufio.NewWriter(conn),
+		enc:               hpack.NewEncoder(hpack.DefaultTableSize),
+		streams:           map[uint32]*stream{},
+		sendWindow:        initialWindowSize,
+		recvWindow:        initialWindowSize,
+		peerInitialWindow: initialWindowSize,
+		peerMaxFrameSize:  minMaxFrameSize,
+	}
+	sc.cond.L = &sc.mu
+	return sc
+}
+
+// byteReader reads a byte slice without copying it first.
+type byteReader struct {
+	buf []byte
+}
+
+func (b *byteReader) Read(p []byte) (int, error) {
+	if len(b.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+func (sc *serverConn) serve(upgrade *request.Request) error {
+	defer sc.shutdown()
+	settings := AppendSettings(nil,
+		Setting{ID: SettingMaxConcurrentStreams, Value: maxConcurrentStreams},
+		Setting{ID: SettingMaxHeaderListSize, Value: maxHeaderListSize},
+	)
+	if err := sc.writeFrame(FrameSettings, 0, 0, settings); err != nil {
+		return err
+	}
+	if upgrade != nil {
+		sc.maxStreamID = 1
+		sc.startStream(1, upgrade, -1, true)
+	}
+	preface := make([]byte, len(ClientPreface))
+	if _, err := io.ReadFull(sc.br, preface); err != nil {
+		return err
+	}
+	if string(preface) != ClientPreface {
+		return sc.goAway(ConnectionError{Code: ErrCodeProtocol, Reason: "invalid connection preface"})
+	}
+	for first := true; ; first = false {
+		f, err := ReadFrame(sc.br, minMaxFrameSize)
+		if errors.Is(err, ErrFrameTooLarge) {
+			return sc.goAway(ConnectionError{Code: ErrCodeFrameSize, Reason: "frame larger than SETTINGS_MAX_FRAME_SIZE"})
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if first && (f.Type != FrameSettings || f.Flags.Has(FlagAck)) {
+			return sc.goAway(ConnectionError{Code: ErrCodeProtocol, Reason: "connection preface must end with SETTINGS"})
+		}
+		err = sc.processFrame(f)
+		var se StreamError
+		if errors.As(err, &se) {
+			sc.resetStream(se)
+			continue
+		}
+		var ce ConnectionError
+		if errors.As(err, &ce) {
+			return sc.goAway(ce)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// goAway tells the client about a connection error and returns it.
+func (sc *serverConn) goAway(ce ConnectionError) error {
+	payload := binary.BigEndian.AppendUint32(nil, sc.maxStreamID)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(ce.Code))
+	payload = append(payload, ce.Reason...)
+	sc.writeFrame(FrameGoAway, 0, 0, payload)
+	return ce
+}
+
+// shutdown stops the handlers still running and waits for them.
+func (sc *serverConn) shutdown() {
+	sc.cancel()
+	sc.mu.Lock()
+	sc.closed = true
+	streams := make([]*stream, 0, len(sc.streams))
+	for _, st := range sc.streams {
+		streams = append(streams, st)
+	}
+	sc.cond.Broadcast()
+	sc.mu.Unlock()
+	for _, st := range streams {
+		st.body.discard(errConnClosed)
+	}
+	// unblock handlers stuck writing to a client that stopped reading
+	sc.conn.SetWriteDeadline(time.Now())
+	sc.handlers.Wait()
+	sc.conn.SetWriteDeadline(time.Time{})
+}
+
+func (sc *serverConn) processFrame(f Frame) error {
+	if sc.continuationStream != 0 && f.Type != FrameContinuation {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "expected CONTINUATION"}
+	}
+	switch f.Type {
+	case FrameData:
+		return sc.processData(f)
+	case FrameHeaders:
+		return sc.processHeaders(f)
+	case FramePriority:
+		return sc.processPriority(f)
+	case FrameRSTStream:
+		return sc.processRSTStream(f)
+	case FrameSettings:
+		return sc.processSettings(f)
+	case FramePushPromise:
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "PUSH_PROMISE from client"}
+	case FramePing:
+		return sc.processPing(f)
+	case FrameGoAway:
+		if f.StreamID != 0 {
+			return ConnectionError{Code: ErrCodeProtocol, Reason: "GOAWAY on a stream"}
+		}
+		// the client opens no more streams; serve the open ones until it
+		// closes the connection
+		return nil
+	case FrameWindowUpdate:
+		return sc.processWindowUpdate(f)
+	case FrameContinuation:
+		return sc.processContinuation(f)
+	default:
+		// unknown frame types are ignored
+		return nil
+	}
+}
+
+func (sc *serverConn) processSettings(f Frame) error {
+	if f.StreamID != 0 {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "SETTINGS on a stream"}
+	}
+	if f.Flags.Has(FlagAck) {
+		if len(f.Payload) != 0 {
+			return ConnectionError{Code: ErrCodeFrameSize, Reason: "SETTINGS ack with payload"}
+		}
+		return nil
+	}
+	settings, err := ParseSettings(f.Payload)
+	if err != nil {
+		return err
+	}
+	if err := sc.applySettings(settings); err != nil {
+		return err
+	}
+	return sc.writeFrame(FrameSettings, FlagAck, 0, nil)
+}
+
+func (sc *serverConn) applySettings(settings []Setting) error {
+	for _, s := range settings {
+		if err := s.Valid(); err != nil {
+			return err
+		}
+		switch s.ID {
+		case SettingHeaderTableSize:
+			sc.writeMu.Lock()
+			sc.enc.SetMaxTableSize(s.Value)
+			sc.writeMu.Unlock()
+		case SettingInitialWindowSize:
+			sc.mu.Lock()
+			delta := int64(s.Value) - sc.peerInitialWindow
+			sc.peerInitialWindow = int64(s.Value)
+			for _, st := range sc.streams {
+				st.sendWindow += delta
+				if st.sendWindow > maxWindowSize {
+					sc.mu.Unlock()
+					return ConnectionError{Code: ErrCodeFlowControl, Reason: "stream window too large"}
+				}
+			}
+			sc.cond.Broadcast()
+			sc.mu.Unlock()
+		case SettingMaxFrameSize:
+			sc.mu.Lock()
+			sc.peerMaxFrameSize = s.Value
+			sc.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+func (sc *serverConn) processPing(f Frame) error {
+	if f.StreamID != 0 {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "PING on a stream"}
+	}
+	if len(f.Payload) != 8 {
+		return ConnectionError{Code: ErrCodeFrameSize, Reason: "PING payload must be 8 bytes"}
+	}
+	if f.Flags.Has(FlagAck) {
+		return nil
+	}
+	return sc.writeFrame(FramePing, FlagAck, 0, f.Payload)
+}
+
+func (sc *serverConn) processWindowUpdate(f Frame) error {
+	if len(f.Payload) != 4 {
+		return ConnectionError{Code: ErrCodeFrameSize, Reason: "WINDOW_UPDATE payload must be 4 bytes"}
+	}
+	increment := int64(binary.BigEndian.Uint32(f.Payload) & (1<<31 - 1))
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if f.StreamID == 0 {
+		if increment == 0 {
+			return ConnectionError{Code: ErrCodeProtocol, Reason: "zero WINDOW_UPDATE increment"}
+		}
+		sc.sendWindow += increment
+		if sc.sendWindow > maxWindowSize {
+			return ConnectionError{Code: ErrCodeFlowControl, Reason: "connection window too large"}
+		}
+		sc.cond.Broadcast()
+		return nil
+	}
+	if f.StreamID > sc.maxStreamID {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "WINDOW_UPDATE on idle stream"}
+	}
+	st := sc.streams[f.StreamID]
+	if st == nil {
+		return nil
+	}
+	if increment == 0 {
+		return StreamError{StreamID: f.StreamID, Code: ErrCodeProtocol, Reason: "zero WINDOW_UPDATE increment"}
+	}
+	st.sendWindow += increment
+	if st.sendWindow > maxWindowSize {
+		return StreamError{StreamID: f.StreamID, Code: ErrCodeFlowControl, Reason: "stream window too large"}
+	}
+	sc.cond.Broadcast()
+	return nil
+}
+
+func (sc *serverConn) processPriority(f Frame) error {
+	if f.StreamID == 0 {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "PRIORITY on stream 0"}
+	}
+	if len(f.Payload) != 5 {
+		return StreamError{StreamID: f.StreamID, Code: ErrCodeFrameSize, Reason: "PRIORITY payload must be 5 bytes"}
+	}
+	// priorities are advisory and ignored, but a stream cannot depend on itself
+	if binary.BigEndian.Uint32(f.Payload)&(1<<31-1) == f.StreamID {
+		return StreamError{StreamID: f.StreamID, Code: ErrCodeProtocol, Reason: "stream depends on itself"}
+	}
+	return nil
+}
+
+func (sc *serverConn) processRSTStream(f Frame) error {
+	if f.StreamID == 0 {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "RST_STREAM on stream 0"}
+	}
+	if len(f.Payload) != 4 {
+		return ConnectionError{Code: ErrCodeFrameSize, Reason: "RST_STREAM payload must be 4 bytes"}
+	}
+	if f.StreamID > sc.maxStreamID {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "RST_STREAM on idle stream"}
+	}
+	sc.closeStream(f.StreamID, errStreamReset)
+	return nil
+}
+
+func (sc *serverConn) processHeaders(f Frame) error {
+	if f.StreamID == 0 {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "HEADERS on stream 0"}
+	}
+	block, err := removePadding(f)
+	if err != nil {
+		return err
+	}
+	sc.headerSelfDepends = false
+	if f.Flags.Has(FlagPriority) {
+		if len(block) < 5 {
+			return ConnectionError{Code: ErrCodeFrameSize, Reason: "HEADERS priority truncated"}
+		}
+		sc.headerSelfDepends = binary.BigEndian.Uint32(block)&(1<<31-1) == f.StreamID
+		block = block[5:]
+	}
+	sc.headerBlock = append(sc.headerBlock[:0], block...)
+	sc.headerStream = f.StreamID
+	sc.headerEndStream = f.Flags.Has(FlagEndStream)
+	if !f.Flags.Has(FlagEndHeaders) {
+		sc.continuationStream = f.StreamID
+		return nil
+	}
+	return sc.endHeaders()
+}
+
+func (sc *serverConn) processContinuation(f Frame) error {
+	if sc.continuationStream == 0 || f.StreamID != sc.continuationStream {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "unexpected CONTINUATION"}
+	}
+	if len(sc.headerBlock)+len(f.Payload) > 2*maxHeaderListSize {
+		return ConnectionError{Code: ErrCodeEnhanceYourCalm, Reason: "header block too large"}
+	}
+	sc.headerBlock = append(sc.headerBlock, f.Payload...)
+	if !f.Flags.Has(FlagEndHeaders) {
+		return nil
+	}
+	sc.continuationStream = 0
+	return sc.endHeaders()
+}
+
+// endHeaders handles a complete header block, which either opens a stream
+// or carries the trailers of its request.
+func (sc *serverConn) endHeaders() error {
+	fields, err := sc.dec.DecodeFields(sc.headerBlock)
+	if err != nil {
+		return ConnectionError{Code: ErrCodeCompression, Reason: err.Error()}
+	}
+	id := sc.headerStream
+	if id%2 == 0 {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "client opened an even stream"}
+	}
+	sc.mu.Lock()
+	st := sc.streams[id]
+	active := len(sc.streams)
+	sc.mu.Unlock()
+	if st != nil {
+		return sc.processTrailers(st, fields)
+	}
+	if id <= sc.maxStreamID {
+		return ConnectionError{Code: ErrCodeStreamClosed, Reason: "HEADERS on closed stream"}
+	}
+	sc.maxStreamID = id
+	if sc.headerSelfDepends {
+		return StreamError{StreamID: id, Code: ErrCodeProtocol, Reason: "stream depends on itself"}
+	}
+	if active >= maxConcurrentStreams {
+		return StreamError{StreamID: id, Code: ErrCodeRefusedStream, Reason: "too many concurrent streams"}
+	}
+	r, contentLength, err := newRequest(fields)
+	if err != nil {
+		return StreamError{StreamID: id, Code: ErrCodeProtocol, Reason: err.Error()}
+	}
+	if sc.headerEndStream && contentLength > 0 {
+		return StreamError{StreamID: id, Code: ErrCodeProtocol, Reason: "content-length without body"}
+	}
+	sc.startStream(id, r, contentLength, sc.headerEndStream)
+	return nil
+}
+
+func (sc *serverConn) processTrailers(st *stream, fields []hpack.HeaderField) error {
+	sc.mu.Lock()
+	remoteClosed := st.remoteClosed
+	sc.mu.Unlock()
+	if remoteClosed {
+		return StreamError{StreamID: st.id, Code: ErrCodeStreamClosed, Reason: "HEADERS after END_STREAM"}
+	}
+	if !sc.headerEndStream {
+		return StreamError{StreamID: st.id, Code: ErrCodeProtocol, Reason: "trailers without END_STREAM"}
+	}
+	for _, f := range fields {
+		if len(f.Name) > 0 && f.Name[0] == ':' {
+			return StreamError{StreamID: st.id, Code: ErrCodeProtocol, Reason: "pseudo-header in trailers"}
+		}
+	}
+	return sc.endRequestBody(st)
+}
+
+func (sc *serverConn) processData(f Frame) error {
+	if f.StreamID == 0 {
+		return ConnectionError{Code: ErrCodeProtocol, Reason: "DATA on stream 0"}
+	}
+	length := int64(len(f.Payload))
+	sc.mu.Lock()
+	if length > sc.recvWindow {
+		sc.mu.Unlock()
+		return ConnectionError{Code: ErrCodeFlowControl, Reason: "connection window exceeded"}
+	}
+	sc.recvWindow -= length
+	st := sc.streams[f.StreamID]
+	var err error
+	switch {
+	case st == nil && f.StreamID > sc.maxStreamID:
+		err = ConnectionError{Code: ErrCodeProtocol, Reason: "DATA on idle stream"}
+	case st == nil:
+		err = StreamError{StreamID: f.StreamID, Code: ErrCodeStreamClosed, Reason: "DATA on closed stream"}
+	case st.remoteClosed:
+		err = StreamError{StreamID: f.StreamID, Code: ErrCodeStreamClosed, Reason: "DATA after END_STREAM"}
+	case length > st.recvWindow:
+		err = StreamError{StreamID: f.StreamID, Code: ErrCodeFlowControl, Reason: "stream window exceeded"}
+	default:
+		st.recvWindow -= length
+	}
+	sc.mu.Unlock()
+	if err != nil {
+		sc.returnWindow(nil, int(length))
+		return err
+	}
+	data, err := removePadding(f)
+	if err != nil {
+		return err
+	}
+	// padding is never read by the handler
+	sc.returnWindow(st, len(f.Payload)-len(data))
+	st.received += int64(len(data))
+	if st.contentLength >= 0 && st.received > st.contentLength {
+		return StreamError{StreamID: st.id, Code: ErrCodeProtocol, Reason: "body longer than content-length"}
+	}
+	if len(data) > 0 && !st.body.write(data) {
+		sc.returnWindow(nil, len(data))
+	}
+	if f.Flags.Has(FlagEndStream) {
+		return sc.endRequestBody(st)
+	}
+	return nil
+}
+
+// endRequestBody handles the END_STREAM flag of a request.
+func (sc *serverConn) endRequestBody(st *stream) error {
+	if st.contentLength >= 0 && st.received != st.contentLength {
+		return StreamError{StreamID: st.id, Code: ErrCodeProtocol, Reason: "body shorter than content-length"}
+	}
+	sc.mu.Lock()
+	st.remoteClosed = true
+	if st.localClosed {
+		delete(sc.streams, st.id)
+	}
+	sc.mu.Unlock()
+	st.body.closeWithError(io.EOF)
+	return nil
+}
+
+// returnWindow gives n consumed bytes back to the client's connection
+// window and, when st is still receiving, to its stream window.
+func (sc *serverConn) returnWindow(st *stream, n int) {
+	if n <= 0 {
+		return
+	}
+	sc.mu.Lock()
+	sc.recvWindow += int64(n)
+	streamUpdate := st != nil && !st.remoteClosed && !st.reset
+	if streamUpdate {
+		st.recvWindow += int64(n)
+	}
+	sc.mu.Unlock()
+	increment := binary.BigEndian.AppendUint32(nil, uint32(n))
+	sc.writeFrame(FrameWindowUpdate, 0, 0, increment)
+	if streamUpdate {
+		sc.writeFrame(FrameWindowUpdate, 0, st.id, increment)
+	}
+}
+
+// resetStream sends RST_STREAM and forgets the stream.
+func (sc *serverConn) resetStream(se StreamError) {
+	sc.writeFrame(FrameRSTStream, 0, se.StreamID, binary.BigEndian.AppendUint32(nil, uint32(se.Code)))
+	sc.closeStream(se.StreamID, errStreamReset)
+}
+
+// closeStream forgets a reset stream, failing its handler's reads and
+// writes.
+func (sc *serverConn) closeStream(id uint32, err error) {
+	sc.mu.Lock()
+	st := sc.streams[id]
+	if st != nil {
+		st.reset = true
+		delete(sc.streams, id)
+		sc.cond.Broadcast()
+	}
+	sc.mu.Unlock()
+	if st == nil {
+		return
+	}
+	st.cancel()
+	sc.returnWindow(nil, st.body.discard(err))
+}
+
+// writeFrame writes a frame and flushes it.
+func (sc *serverConn) writeFrame(t FrameType, flags Flags, streamID uint32, payload []byte) error {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	if err := WriteFrame(sc.bw, t, flags, streamID, payload); err != nil {
+		return err
+	}
+	return sc.bw.Flush()
+}
@@ -0,0 +1,418 @@
+package http2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/headers"
+	"http/internal/hpack"
+	"http/internal/request"
+	"http/internal/response"
+)
+
+// serve accepts connections on a local listener and serves HTTP/2 on them.
+func serve(t *testing.T, handler Handler) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ServeConn(conn, nil, handler)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// client is a minimal HTTP/2 client speaking raw frames.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	enc  *hpack.Encoder
+	dec  *hpack.Decoder
+}
+
+func dial(t *testing.T, addr string, settings ...Setting) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	c := &client{t: t, conn: conn, enc: hpack.NewEncoder(hpack.DefaultTableSize), dec: hpack.NewDecoder(hpack.DefaultTableSize, maxHeaderListSize)}
+	_, err = conn.Write([]byte(ClientPreface))
+	require.NoError(t, err)
+	c.write(FrameSettings, 0, 0, AppendSettings(nil, settings...))
+	f := c.read()
+	require.Equal(t, FrameSettings, f.Type)
+	require.False(t, f.Flags.Has(FlagAck))
+	f = c.read()
+	require.Equal(t, FrameSettings, f.Type)
+	require.True(t, f.Flags.Has(FlagAck))
+	return c
+}
+
+func (c *client) write(t FrameType, flags Flags, streamID uint32, payload []byte) {
+	c.t.Helper()
+	require.NoError(c.t, WriteFrame(c.conn, t, flags, streamID, payload))
+}
+
+func (c *client) read() Frame {
+	c.t.Helper()
+	f, err := ReadFrame(c.conn, maxMaxFrameSize)
+	require.NoError(c.t, err)
+	return f
+}
+
+// readSkipping reads the next frame that is not a WINDOW_UPDATE.
+func (c *client) readSkipping() Frame {
+	c.t.Helper()
+	for {
+		f := c.read()
+		if f.Type != FrameWindowUpdate {
+			return f
+		}
+	}
+}
+
+func (c *client) request(streamID uint32, endStream bool, fields ...string) {
+	c.t.Helper()
+	var hf []hpack.HeaderField
+	for i := 0; i < len(fields); i += 2 {
+		hf = append(hf, hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+	}
+	flags := FlagEndHeaders
+	if endStream {
+		flags |= FlagEndStream
+	}
+	c.write(FrameHeaders, flags, streamID, c.enc.EncodeFields(nil, hf))
+}
+
+func (c *client) get(streamID uint32, path string) {
+	c.t.Helper()
+	c.request(streamID, true, ":method", "GET", ":scheme", "http", ":path", path, ":authority", "example.com")
+}
+
+// response reads a header block and the body up to END_STREAM.
+func (c *client) response(streamID uint32) (map[string]string, string) {
+	c.t.Helper()
+	f := c.readSkipping()
+	require.Equal(c.t, FrameHeaders, f.Type, "got %s", f.Type)
+	require.Equal(c.t, streamID, f.StreamID)
+	fields, err := c.dec.DecodeFields(f.Payload)
+	require.NoError(c.t, err)
+	h := map[string]string{}
+	for _, hf := range fields {
+		h[hf.Name] = hf.Value
+	}
+	var body bytes.Buffer
+	for end := f.Flags.Has(FlagEndStream); !end; {
+		f = c.readSkipping()
+		require.Equal(c.t, streamID, f.StreamID)
+		switch f.Type {
+		case FrameData:
+			body.Write(f.Payload)
+		case FrameHeaders:
+			fields, err := c.dec.DecodeFields(f.Payload)
+			require.NoError(c.t, err)
+			for _, hf := range fields {
+				h["trailer:"+hf.Name] = hf.Value
+			}
+		default:
+			c.t.Fatalf("unexpected %s frame", f.Type)
+		}
+		end = f.Flags.Has(FlagEndStream)
+	}
+	return h, body.String()
+}
+
+func (c *client) expectRST(streamID uint32, code ErrCode) {
+	c.t.Helper()
+	f := c.readSkipping()
+	require.Equal(c.t, FrameRSTStream, f.Type, "got %s", f.Type)
+	assert.Equal(c.t, streamID, f.StreamID)
+	assert.Equal(c.t, code, ErrCode(binary.BigEndian.Uint32(f.Payload)))
+}
+
+func (c *client) expectGoAway(code ErrCode) {
+	c.t.Helper()
+	f := c.readSkipping()
+	require.Equal(c.t, FrameGoAway, f.Type, "got %s", f.Type)
+	assert.Equal(c.t, code, ErrCode(binary.BigEndian.Uint32(f.Payload[4:])), string(f.Payload[8:]))
+}
+
+func windowUpdate(n uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, n)
+}
+
+func echo(w *response.Writer, r *request.Request) {
+	body, err := io.ReadAll(r.BodyReader())
+	if err != nil {
+		return
+	}
+	out := []byte(r.RequestLine.Method + " " + r.RequestLine.RequestTarget + " " + r.Headers.Get("Host") + " " +
+		r.Headers.Get("Cookie") + " " + string(body))
+	h := response.GetDefaultHeaders(len(out))
+	w.WriteStatusLine(response.StatusOk)
+	w.WriteHeaders(h)
+	w.WriteBody(out)
+}
+
+func TestRequestResponse(t *testing.T) {
+	c := dial(t, serve(t, echo))
+
+	// Test: GET with the connection-specific fields of the defaults dropped
+	c.get(1, "/hello")
+	h, body := c.response(1)
+	assert.Equal(t, "200", h[":status"])
+	assert.Equal(t, "text/plain", h["content-type"])
+	assert.NotContains(t, h, "connection")
+	assert.Equal(t, "GET /hello example.com  ", body)
+
+	// Test: POST with a padded body and split cookies
+	c.request(3, false, ":method", "POST", ":scheme", "http", ":path", "/upload", ":authority", "example.com",
+		"cookie", "a=1", "cookie", "b=2")
+	c.write(FrameData, FlagPadded, 3, append([]byte{3}, "hel\x00\x00\x00"...))
+	c.write(FrameData, FlagEndStream, 3, []byte("lo"))
+	h, body = c.response(3)
+	assert.Equal(t, "200", h[":status"])
+	assert.Equal(t, "POST /upload example.com a=1; b=2 hello", body)
+
+	// Test: HEAD gets headers only
+	c.request(5, true, ":method", "HEAD", ":scheme", "http", ":path", "/", ":authority", "example.com")
+	h, body = c.response(5)
+	assert.Equal(t, "200", h[":status"])
+	assert.Empty(t, body)
+}
+
+func TestMultiplexing(t *testing.T) {
+	release := make(chan struct{})
+	c := dial(t, serve(t, func(w *response.Writer, r *request.Request) {
+		if r.RequestLine.RequestTarget == "/slow" {
+			<-release
+		}
+		body := []byte(r.RequestLine.RequestTarget)
+		w.WriteStatusLine(response.StatusOk)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody(body)
+	}))
+
+	// Test: A blocked stream does not hold up the others
+	c.get(1, "/slow")
+	c.get(3, "/fast")
+	_, body := c.response(3)
+	assert.Equal(t, "/fast", body)
+	close(release)
+	_, body = c.response(1)
+	assert.Equal(t, "/slow", body)
+}
+
+func TestTrailersAndCookies(t *testing.T) {
+	c := dial(t, serve(t, func(w *response.Writer, r *request.Request) {
+		w.WriteStatusLine(response.StatusOk)
+		h := response.GetDefaultHeaders(0)
+		h.Remove("Content-Length")
+		h.Set("Transfer-Encoding", "chunked")
+		h.Set("Trailer", "X-Checksum")
+		w.SetCookie(&headers.Cookie{Name: "a", Value: "1"})
+		w.SetCookie(&headers.Cookie{Name: "b", Value: "2"})
+		w.WriteHeaders(h)
+		w.WriteChunkedBody([]byte("part one, "))
+		w.WriteChunkedBody([]byte("part two"))
+		trailers := headers.NewHeaders()
+		trailers.Set("X-Checksum", "abc")
+		w.WriteTrailers(trailers)
+	}))
+	c.get(1, "/")
+	f := c.readSkipping()
+	require.Equal(t, FrameHeaders, f.Type)
+	fields, err := c.dec.DecodeFields(f.Payload)
+	require.NoError(t, err)
+	var cookies []string
+	for _, hf := range fields {
+		assert.NotEqual(t, "transfer-encoding", hf.Name)
+		if hf.Name == "set-cookie" {
+			cookies = append(cookies, hf.Value)
+		}
+	}
+	assert.Equal(t, []string{"a=1", "b=2"}, cookies)
+	var body bytes.Buffer
+	for {
+		f = c.readSkipping()
+		if f.Type != FrameData {
+			break
+		}
+		body.Write(f.Payload)
+	}
+	assert.Equal(t, "part one, part two", body.String())
+	require.Equal(t, FrameHeaders, f.Type)
+	assert.True(t, f.Flags.Has(FlagEndStream))
+	fields, err = c.dec.DecodeFields(f.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, []hpack.HeaderField{{Name: "x-checksum", Value: "abc"}}, fields)
+}
+
+func TestFlowControl(t *testing.T) {
+	body := strings.Repeat("x", 25)
+	c := dial(t, serve(t, func(w *response.Writer, r *request.Request) {
+		w.WriteStatusLine(response.StatusOk)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody([]byte(body))
+	}), Setting{ID: SettingInitialWindowSize, Value: 10})
+
+	// Test: Data stops at the stream window until it is opened
+	c.get(1, "/")
+	f := c.readSkipping()
+	require.Equal(t, FrameHeaders, f.Type)
+	f = c.readSkipping()
+	require.Equal(t, FrameData, f.Type)
+	assert.Len(t, f.Payload, 10)
+	c.write(FramePing, 0, 0, []byte("12345678"))
+	f = c.readSkipping()
+	require.Equal(t, FramePing, f.Type, "server sent %s beyond the window", f.Type)
+	assert.True(t, f.Flags.Has(FlagAck))
+	assert.Equal(t, "12345678", string(f.Payload))
+
+	c.write(FrameWindowUpdate, 0, 1, windowUpdate(100))
+	received := 10
+	for end := false; !end; {
+		f = c.readSkipping()
+		require.Equal(t, FrameData, f.Type)
+		received += len(f.Payload)
+		end = f.Flags.Has(FlagEndStream)
+	}
+	assert.Equal(t, len(body), received)
+
+	// Test: Window overflow is a flow control error
+	c.write(FrameWindowUpdate, 0, 0, windowUpdate(maxWindowSize))
+	c.expectGoAway(ErrCodeFlowControl)
+}
+
+func TestReceiveWindow(t *testing.T) {
+	c := dial(t, serve(t, echo))
+
+	// Test: Sending more than the window is a flow control error
+	c.request(1, false, ":method", "POST", ":scheme", "http", ":path", "/", ":authority", "example.com")
+	chunk := make([]byte, minMaxFrameSize)
+	for i := 0; i < 4; i++ {
+		c.write(FrameData, 0, 1, chunk)
+	}
+	c.write(FrameData, 0, 1, make([]byte, initialWindowSize-4*minMaxFrameSize+1))
+	c.expectGoAway(ErrCodeFlowControl)
+}
+
+func TestStreamErrors(t *testing.T) {
+	c := dial(t, serve(t, echo))
+	get := []string{":method", "GET", ":scheme", "http", ":path", "/"}
+
+	// Test: Connection-specific fields
+	c.request(1, true, append(get, "connection", "keep-alive")...)
+	c.expectRST(1, ErrCodeProtocol)
+
+	// Test: Upper case field names
+	c.request(3, true, append(get, "X-Upper", "1")...)
+	c.expectRST(3, ErrCodeProtocol)
+
+	// Test: Missing pseudo-header
+	c.request(5, true, ":method", "GET", ":path", "/")
+	c.expectRST(5, ErrCodeProtocol)
+
+	// Test: Pseudo-header after a regular field
+	c.request(7, true, ":method", "GET", ":scheme", "http", "accept", "*/*", ":path", "/")
+	c.expectRST(7, ErrCodeProtocol)
+
+	// Test: Body shorter than content-length
+	c.request(9, false, append(get, "content-length", "5")...)
+	c.write(FrameData, FlagEndStream, 9, []byte("abc"))
+	c.expectRST(9, ErrCodeProtocol)
+
+	// Test: The connection is still usable
+	c.get(11, "/ok")
+	h, _ := c.response(11)
+	assert.Equal(t, "200", h[":status"])
+}
+
+func TestConnectionErrors(t *testing.T) {
+	addr := serve(t, echo)
+
+	// Test: DATA on stream 0
+	c := dial(t, addr)
+	c.write(FrameData, 0, 0, []byte("x"))
+	c.expectGoAway(ErrCodeProtocol)
+
+	// Test: Even stream ID
+	c = dial(t, addr)
+	c.get(2, "/")
+	c.expectGoAway(ErrCodeProtocol)
+
+	// Test: Frame interleaved with a header block
+	c = dial(t, addr)
+	c.write(FrameHeaders, 0, 1, c.enc.EncodeFields(nil, []hpack.HeaderField{{Name: ":method", Value: "GET"}}))
+	c.write(FramePing, 0, 0, make([]byte, 8))
+	c.expectGoAway(ErrCodeProtocol)
+
+	// Test: Invalid header block
+	c = dial(t, addr)
+	c.write(FrameHeaders, FlagEndHeaders|FlagEndStream, 1, []byte{0xff, 0xff, 0xff, 0xff, 0x0f})
+	c.expectGoAway(ErrCodeCompression)
+
+	// Test: Frame larger than SETTINGS_MAX_FRAME_SIZE
+	c = dial(t, addr)
+	c.write(FramePing, 0, 0, make([]byte, minMaxFrameSize+1))
+	c.expectGoAway(ErrCodeFrameSize)
+
+	// Test: Wrong preface
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write([]byte("PRI * HTTP/2.0\r\n\r\nXX\r\n\r\n"))
+	require.NoError(t, err)
+	c = &client{t: t, conn: conn}
+	f := c.read()
+	require.Equal(t, FrameSettings, f.Type)
+	c.expectGoAway(ErrCodeProtocol)
+}
+
+func TestReset(t *testing.T) {
+	cancelled := make(chan struct{})
+	c := dial(t, serve(t, func(w *response.Writer, r *request.Request) {
+		if r.RequestLine.RequestTarget == "/empty" {
+			return
+		}
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+
+	// Test: RST_STREAM from the client cancels the request context
+	c.get(1, "/")
+	c.write(FrameRSTStream, 0, 1, binary.BigEndian.AppendUint32(nil, uint32(ErrCodeCancel)))
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request context not cancelled")
+	}
+
+	// Test: Nothing is sent on a stream reset by the client
+	c.write(FramePing, 0, 0, []byte("12345678"))
+	f := c.readSkipping()
+	assert.Equal(t, FramePing, f.Type)
+
+	// Test: A handler writing nothing resets its stream
+	c.get(3, "/empty")
+	c.expectRST(3, ErrCodeInternal)
+}
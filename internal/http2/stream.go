@@ -0,0 +1,350 @@
+package http2
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"http/internal/headers"
+	"http/internal/hpack"
+	"http/internal/request"
+	"http/internal/response"
+)
+
+var errSwitchingProtocols = errors.New("http2: 101 Switching Protocols is not allowed")
+
+// connectionSpecific lists the HTTP/1.1 fields that have no meaning in
+// HTTP/2 (RFC 9113 section 8.2.2). Requests carrying them are malformed and
+// responses drop them.
+var connectionSpecific = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// stream is a request and its response. It is the response.Framer of the
+// handler's Writer.
+type stream struct {
+	sc     *serverConn
+	id     uint32
+	cancel context.CancelFunc
+	body   *pipe
+
+	// guarded by sc.mu
+	sendWindow   int64
+	recvWindow   int64
+	remoteClosed bool
+	localClosed  bool
+	reset        bool
+
+	// used by the read loop only
+	contentLength int64
+	received      int64
+
+	// used by the handler only
+	headersSent bool
+}
+
+// startStream runs the handler for a new request. contentLength is -1 when
+// the request has no Content-Length.
+func (sc *serverConn) startStream(id uint32, r *request.Request, contentLength int64, endStream bool) {
+	ctx, cancel := context.WithCancel(sc.ctx)
+	r.SetContext(ctx)
+	st := &stream{
+		sc:            sc,
+		id:            id,
+		cancel:        cancel,
+		remoteClosed:  endStream,
+		contentLength: contentLength,
+		recvWindow:    initialWindowSize,
+	}
+	st.body = newPipe(func(n int) { sc.returnWindow(st, n) })
+	if endStream {
+		st.body.closeWithError(io.EOF)
+	} else {
+		r.SetBodyReader(st.body)
+	}
+	sc.mu.Lock()
+	st.sendWindow = sc.peerInitialWindow
+	sc.streams[id] = st
+	sc.mu.Unlock()
+
+	sc.handlers.Add(1)
+	go func() {
+		defer sc.handlers.Done()
+		defer cancel()
+		w := response.NewFramedWriter(st)
+		w.SetRequestMethod(r.RequestLine.Method)
+		sc.handler(w, r)
+		w.Finish()
+		st.finish()
+	}()
+}
+
+// finish ends the response once the handler has returned.
+func (st *stream) finish() {
+	sc := st.sc
+	sc.mu.Lock()
+	reset, localClosed, remoteClosed := st.reset, st.localClosed, st.remoteClosed
+	sc.mu.Unlock()
+	switch {
+	case reset:
+		return
+	case !st.headersSent:
+		sc.resetStream(StreamError{StreamID: st.id, Code: ErrCodeInternal, Reason: "no response"})
+		return
+	case !localClosed:
+		if _, err := sc.writeData(st, nil, true); err != nil {
+			return
+		}
+	}
+	if !remoteClosed {
+		// the rest of the request body is not needed
+		sc.resetStream(StreamError{StreamID: st.id, Code: ErrCodeNo})
+		return
+	}
+	sc.returnWindow(nil, st.body.discard(errStreamClosed))
+}
+
+func (st *stream) WriteHeaders(statusCode response.StatusCode, h headers.Headers, setCookies []string) error {
+	if statusCode == response.StatusSwitchingProtocols {
+		return errSwitchingProtocols
+	}
+	fields := []hpack.HeaderField{{Name: ":status", Value: strconv.Itoa(int(statusCode))}}
+	fields = appendFields(fields, h)
+	for _, c := range setCookies {
+		fields = append(fields, hpack.HeaderField{Name: "set-cookie", Value: c})
+	}
+	if err := st.sc.writeHeaders(st, fields, false); err != nil {
+		return err
+	}
+	if statusCode >= 200 {
+		st.headersSent = true
+	}
+	return nil
+}
+
+func (st *stream) WriteData(p []byte) (int, error) {
+	return st.sc.writeData(st, p, false)
+}
+
+func (st *stream) WriteTrailers(h headers.Headers) error {
+	fields := appendFields(nil, h)
+	if len(fields) == 0 {
+		_, err := st.sc.writeData(st, nil, true)
+		return err
+	}
+	return st.sc.writeHeaders(st, fields, true)
+}
+
+// appendFields appends h in sorted order, leaving out connection-specific
+// fields.
+func appendFields(fields []hpack.HeaderField, h headers.Headers) []hpack.HeaderField {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		if !connectionSpecific[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields = append(fields, hpack.HeaderField{Name: k, Value: h[k]})
+	}
+	return fields
+}
+
+// writable reports why frames can no longer be sent on st.
+func (sc *serverConn) writable(st *stream) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.closed {
+		return errConnClosed
+	}
+	if st.reset || st.localClosed {
+		return errStreamClosed
+	}
+	return nil
+}
+
+// endLocal records that the response has been sent entirely.
+func (sc *serverConn) endLocal(st *stream) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	st.localClosed = true
+	if st.remoteClosed {
+		delete(sc.streams, st.id)
+	}
+}
+
+// writeHeaders sends a header block in a HEADERS frame and as many
+// CONTINUATION frames as needed.
+func (sc *serverConn) writeHeaders(st *stream, fields []hpack.HeaderField, endStream bool) error {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	if err := sc.writable(st); err != nil {
+		return err
+	}
+	sc.mu.Lock()
+	maxFrameSize := int(sc.peerMaxFrameSize)
+	sc.mu.Unlock()
+	block := sc.enc.EncodeFields(nil, fields)
+	t, flags := FrameHeaders, Flags(0)
+	if endStream {
+		flags = FlagEndStream
+	}
+	for first := true; first || len(block) > 0; first = false {
+		chunk := block[:min(len(block), maxFrameSize)]
+		block = block[len(chunk):]
+		if len(block) == 0 {
+			flags |= FlagEndHeaders
+		}
+		if err := WriteFrame(sc.bw, t, flags, st.id, chunk); err != nil {
+			return err
+		}
+		t, flags = FrameContinuation, 0
+	}
+	if err := sc.bw.Flush(); err != nil {
+		return err
+	}
+	if endStream {
+		sc.endLocal(st)
+	}
+	return nil
+}
+
+// writeData sends p in DATA frames as the flow control windows allow,
+// waiting for the client to open them.
+func (sc *serverConn) writeData(st *stream, p []byte, endStream bool) (int, error) {
+	written := 0
+	for {
+		n := 0
+		if len(p) > 0 {
+			sc.mu.Lock()
+			for !sc.closed && !st.reset && (st.sendWindow <= 0 || sc.sendWindow <= 0) {
+				sc.cond.Wait()
+			}
+			if sc.closed || st.reset {
+				sc.mu.Unlock()
+				return written, errStreamClosed
+			}
+			n = int(min(int64(len(p)), st.sendWindow, sc.sendWindow, int64(sc.peerMaxFrameSize)))
+			st.sendWindow -= int64(n)
+			sc.sendWindow -= int64(n)
+			sc.mu.Unlock()
+		}
+		end := endStream && n == len(p)
+		if err := sc.writeDataFrame(st, p[:n], end); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+		if len(p) == 0 {
+			return written, nil
+		}
+	}
+}
+
+func (sc *serverConn) writeDataFrame(st *stream, data []byte, endStream bool) error {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	if err := sc.writable(st); err != nil {
+		return err
+	}
+	var flags Flags
+	if endStream {
+		flags = FlagEndStream
+	}
+	if err := WriteFrame(sc.bw, FrameData, flags, st.id, data); err != nil {
+		return err
+	}
+	if err := sc.bw.Flush(); err != nil {
+		return err
+	}
+	if endStream {
+		sc.endLocal(st)
+	}
+	return nil
+}
+
+// newRequest builds a request from a decoded header block, checking the
+// rules of RFC 9113 section 8.2 and 8.3. It also returns the declared
+// content length, or -1.
+func newRequest(fields []hpack.HeaderField) (*request.Request, int64, error) {
+	h := headers.NewHeaders()
+	pseudo := map[string]string{}
+	var cookies []string
+	regular := false
+	size := 0
+	for _, f := range fields {
+		size += int(f.Size())
+		if strings.HasPrefix(f.Name, ":") {
+			if regular {
+				return nil, 0, errors.New("pseudo-header after regular field")
+			}
+			switch f.Name {
+			case ":method", ":scheme", ":path", ":authority":
+			default:
+				return nil, 0, errors.New("unknown pseudo-header " + f.Name)
+			}
+			if _, ok := pseudo[f.Name]; ok {
+				return nil, 0, errors.New("duplicate pseudo-header " + f.Name)
+			}
+			pseudo[f.Name] = f.Value
+			continue
+		}
+		regular = true
+		if !headers.ValidFieldName(f.Name) || strings.ToLower(f.Name) != f.Name {
+			return nil, 0, errors.New("invalid field name")
+		}
+		if strings.ContainsAny(f.Value, "\r\n\x00") || strings.TrimSpace(f.Value) != f.Value {
+			return nil, 0, errors.New("invalid field value")
+		}
+		if connectionSpecific[f.Name] || f.Name == "te" && f.Value != "trailers" {
+			return nil, 0, errors.New("connection-specific field " + f.Name)
+		}
+		if f.Name == "cookie" {
+			cookies = append(cookies, f.Value)
+			continue
+		}
+		h.Set(f.Name, f.Value)
+	}
+	if size > maxHeaderListSize {
+		return nil, 0, errors.New("header list too large")
+	}
+	if len(cookies) > 0 {
+		h.Override("cookie", strings.Join(cookies, "; "))
+	}
+	method, path := pseudo[":method"], pseudo[":path"]
+	authority, hasAuthority := pseudo[":authority"]
+	if method == "CONNECT" {
+		if authority == "" || pseudo[":scheme"] != "" || path != "" {
+			return nil, 0, errors.New("malformed CONNECT request")
+		}
+		path = authority
+	} else if method == "" || pseudo[":scheme"] == "" || path == "" {
+		return nil, 0, errors.New("missing pseudo-header")
+	}
+	if hasAuthority {
+		h.Override("host", authority)
+	}
+	contentLength := int64(-1)
+	if n, ok, err := h.ContentLength(); err != nil {
+		return nil, 0, err
+	} else if ok {
+		contentLength = n
+	}
+	r := &request.Request{
+		RequestLine: request.RequestLine{
+			Method:        method,
+			RequestTarget: path,
+			HttpVersion:   "2",
+		},
+		Headers: h,
+	}
+	return r, contentLength, nil
+}
@@ -0,0 +1,90 @@
+package jsonhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"http/internal/request"
+	"http/internal/response"
+)
+
+// DefaultMaxBodySize is the largest body DecodeJSON accepts.
+const DefaultMaxBodySize = 1 << 20
+
+// DecodeJSON decodes a JSON request body into v with DecodeJSONSize and
+// DefaultMaxBodySize.
+func DecodeJSON(r *request.Request, v any) error {
+	return DecodeJSONSize(r, v, DefaultMaxBodySize)
+}
+
+// DecodeJSONSize decodes a JSON request body of at most maxSize bytes into
+// v. Failures are returned as a *Problem: 415 when the Content-Type is not
+// JSON, 413 when the body is too large and 400 when it is malformed, has
+// more than one value or has fields v does not have.
+func DecodeJSONSize(r *request.Request, v any, maxSize int64) error {
+	mediaType, _ := r.Headers.ContentType()
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return NewProblem(response.StatusUnsupportedMediaType,
+			"Content-Type must be application/json")
+	}
+	dec := json.NewDecoder(request.LimitReader(r.BodyReader(), maxSize))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		// anything but whitespace after the value is an error
+		if _, err = dec.Token(); errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err == nil {
+			err = errors.New("body must contain a single JSON value")
+		}
+	}
+	return decodeProblem(err)
+}
+
+func decodeProblem(err error) *Problem {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, request.ErrBodyTooLarge):
+		return NewProblem(response.StatusRequestEntityTooLarge, "request body too large")
+	case errors.Is(err, io.EOF):
+		return NewProblem(response.StatusBadRequest, "request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return NewProblem(response.StatusBadRequest, "request body is truncated")
+	case errors.As(err, &syntaxErr):
+		return NewProblem(response.StatusBadRequest,
+			fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		return NewProblem(response.StatusBadRequest,
+			fmt.Sprintf("field %q must be of type %s", typeErr.Field, typeErr.Type))
+	}
+	return NewProblem(response.StatusBadRequest, strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// WriteJSON sends v as an application/json response with the given status
+// code. Nothing is written if v cannot be encoded.
+func WriteJSON(w *response.Writer, status response.StatusCode, v any) error {
+	return writeJSON(w, status, "application/json", v)
+}
+
+func writeJSON(w *response.Writer, status response.StatusCode, contentType string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if err := w.WriteStatusLine(status); err != nil {
+		return err
+	}
+	h := response.GetDefaultHeaders(len(data))
+	h.Override("Content-Type", contentType)
+	if err := w.WriteHeaders(h); err != nil {
+		return err
+	}
+	_, err = w.WriteBody(data)
+	return err
+}
@@ -0,0 +1,136 @@
+package jsonhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/request"
+	"http/internal/response"
+)
+
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func jsonRequest(t *testing.T, contentType, body string) *request.Request {
+	t.Helper()
+	r, err := request.RequestHeadersFromReader(strings.NewReader(
+		"POST /users HTTP/1.1\r\n" +
+			"Content-Type: " + contentType + "\r\n" +
+			"Content-Length: " + strconv.Itoa(len(body)) + "\r\n" +
+			"\r\n" + body))
+	require.NoError(t, err)
+	return r
+}
+
+func TestDecodeJSON(t *testing.T) {
+	// Test: Valid body
+	var u user
+	require.NoError(t, DecodeJSON(jsonRequest(t, "application/json; charset=utf-8", `{"name":"ana","age":3}`), &u))
+	assert.Equal(t, user{Name: "ana", Age: 3}, u)
+	require.NoError(t, DecodeJSON(jsonRequest(t, "application/merge-patch+json", `{"age":4} `), &u))
+	assert.Equal(t, 4, u.Age)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      response.StatusCode
+	}{
+		{"wrong media type", "text/plain", `{}`, response.StatusUnsupportedMediaType},
+		{"missing media type", "", `{}`, response.StatusUnsupportedMediaType},
+		{"syntax error", "application/json", `{"name":}`, response.StatusBadRequest},
+		{"truncated", "application/json", `{"name":"a"`, response.StatusBadRequest},
+		{"empty", "application/json", ``, response.StatusBadRequest},
+		{"unknown field", "application/json", `{"nam":"a"}`, response.StatusBadRequest},
+		{"wrong type", "application/json", `{"age":"old"}`, response.StatusBadRequest},
+		{"two values", "application/json", `{} {}`, response.StatusBadRequest},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", DefaultMaxBodySize) + `"}`, response.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		err := DecodeJSON(jsonRequest(t, tt.contentType, tt.body), &user{})
+		var p *Problem
+		require.True(t, errors.As(err, &p), tt.name)
+		assert.Equal(t, tt.status, p.Status, tt.name)
+	}
+
+	// Test: A body cut short by the decoded size limit is too large
+	zbuf := &bytes.Buffer{}
+	zw := gzip.NewWriter(zbuf)
+	zw.Write([]byte(`{"name":"` + strings.Repeat("a", 1000) + `"}`))
+	zw.Close()
+	r, err := request.RequestHeadersFromReader(strings.NewReader(
+		"POST /users HTTP/1.1\r\nContent-Type: application/json\r\nContent-Encoding: gzip\r\n" +
+			"Content-Length: " + strconv.Itoa(zbuf.Len()) + "\r\n\r\n" + zbuf.String()))
+	require.NoError(t, err)
+	require.NoError(t, r.DecodeBody(100))
+	var p *Problem
+	require.True(t, errors.As(DecodeJSON(r, &user{}), &p))
+	assert.Equal(t, response.StatusRequestEntityTooLarge, p.Status)
+}
+
+func TestWriteJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+	require.NoError(t, WriteJSON(w, response.StatusOk, user{Name: "ana", Age: 3}))
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"connection: close\r\n"+
+			"content-length: 23\r\n"+
+			"content-type: application/json\r\n"+
+			"\r\n"+
+			`{"name":"ana","age":3}`+"\n",
+		buf.String())
+
+	// Test: Unencodable values write nothing
+	buf.Reset()
+	w = response.NewWriter(buf)
+	require.Error(t, WriteJSON(w, response.StatusOk, make(chan int)))
+	assert.Empty(t, buf.String())
+}
+
+func TestProblem(t *testing.T) {
+	p := NewProblem(response.StatusNotFound, "no user 7")
+	p.Instance = "/users/7"
+	p.Extensions = map[string]any{"id": 7, "status": "ignored"}
+	assert.Equal(t, "Not Found: no user 7", p.Error())
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, p.Write(response.NewWriter(buf)))
+	head, body, ok := strings.Cut(buf.String(), "\r\n\r\n")
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 404 Not Found\r\n"))
+	assert.Contains(t, head, "content-type: application/problem+json")
+
+	var members map[string]any
+	require.NoError(t, json.Unmarshal([]byte(body), &members))
+	assert.Equal(t, map[string]any{
+		"type":     "about:blank",
+		"title":    "Not Found",
+		"status":   float64(404),
+		"detail":   "no user 7",
+		"instance": "/users/7",
+		"id":       float64(7),
+	}, members)
+
+	// Test: Extensions never stand in for unset standard members
+	p = &Problem{Extensions: map[string]any{"status": "x", "title": "t", "detail": "d", "instance": "i", "type": "y"}}
+	buf = &bytes.Buffer{}
+	require.NoError(t, p.Write(response.NewWriter(buf)))
+	head, body, ok = strings.Cut(buf.String(), "\r\n\r\n")
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 500 Internal Server Error\r\n"))
+	members = nil
+	require.NoError(t, json.Unmarshal([]byte(body), &members))
+	assert.Equal(t, map[string]any{"type": "about:blank", "status": float64(500)}, members)
+	assert.Zero(t, p.Status)
+}
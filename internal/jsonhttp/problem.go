@@ -0,0 +1,79 @@
+package jsonhttp
+
+import (
+	"encoding/json"
+
+	"http/internal/response"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details object. It is an error, so that
+// helpers such as DecodeJSON can return one for the handler to write.
+type Problem struct {
+	// Type is a URI identifying the problem type. It defaults to
+	// "about:blank", meaning the problem is described by Status alone.
+	Type     string
+	Title    string
+	Status   response.StatusCode
+	Detail   string
+	Instance string
+	// Extensions are additional members serialised next to the standard
+	// ones. Members clashing with a standard member are ignored.
+	Extensions map[string]any
+}
+
+// NewProblem returns a Problem titled with the reason phrase of status.
+func NewProblem(status response.StatusCode, detail string) *Problem {
+	return &Problem{
+		Title:  response.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := map[string]any{}
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, k)
+	}
+	members["type"] = p.Type
+	if p.Type == "" {
+		members["type"] = "about:blank"
+	}
+	if p.Title != "" {
+		members["title"] = p.Title
+	}
+	if p.Status != 0 {
+		members["status"] = p.Status
+	}
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+// Write sends the problem as an application/problem+json response with its
+// status code, or 500 if it has none.
+func (p *Problem) Write(w *response.Writer) error {
+	if p.Status == 0 {
+		// the body must agree with the status code actually sent
+		withStatus := *p
+		withStatus.Status = response.StatusInternalServerError
+		p = &withStatus
+	}
+	return writeJSON(w, p.Status, ProblemContentType, p)
+}
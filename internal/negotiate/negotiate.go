@@ -0,0 +1,181 @@
+package negotiate
+
+import (
+	"strconv"
+	"strings"
+
+	"http/internal/headers"
+)
+
+// Spec is one element of an Accept* header: a value such as a media range,
+// language range or charset, its parameters and its weight.
+type Spec struct {
+	Value  string
+	Params map[string]string
+	Q      float64
+}
+
+// ParseAccept parses an Accept* header value. Values are lower cased and
+// elements with a malformed weight are dropped. Parameters after the weight
+// are accept-ext and are ignored, and parameters without a value are kept
+// with an empty value.
+func ParseAccept(header string) []Spec {
+	var specs []Spec
+	for _, element := range headers.SplitList(header) {
+		parts := headers.SplitParameters(element)
+		value := strings.ToLower(strings.TrimSpace(parts[0]))
+		if value == "" {
+			continue
+		}
+		spec := Spec{Value: value, Q: 1}
+		valid := true
+		for _, param := range parts[1:] {
+			name, val, _ := strings.Cut(param, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			val = strings.TrimSpace(val)
+			if name == "" {
+				continue
+			}
+			if unquoted, err := headers.Unquote(val); err == nil {
+				val = unquoted
+			}
+			if name == "q" {
+				q, err := strconv.ParseFloat(val, 64)
+				valid = err == nil && q >= 0 && q <= 1
+				spec.Q = q
+				break
+			}
+			if spec.Params == nil {
+				spec.Params = map[string]string{}
+			}
+			spec.Params[name] = val
+		}
+		if valid {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// ContentType returns the offered media type preferred by an Accept header.
+// Offers are tried in order, so the first offer wins ties. It reports false
+// when none of the offers is acceptable. A missing header accepts anything.
+func ContentType(accept string, offers []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return first(offers)
+	}
+	specs := ParseAccept(accept)
+	return best(offers, func(offer string) float64 {
+		offerType, offerParams := parseMediaType(offer)
+		q, specificity := -1.0, -1
+		for _, spec := range specs {
+			s := mediaRangeMatch(spec, offerType, offerParams)
+			if s > specificity {
+				q, specificity = spec.Q, s
+			}
+		}
+		return q
+	})
+}
+
+// Language returns the offered language tag preferred by an
+// Accept-Language header, using basic filtering: the range "en" matches
+// the tags "en" and "en-GB".
+func Language(acceptLanguage string, offers []string) (string, bool) {
+	if strings.TrimSpace(acceptLanguage) == "" {
+		return first(offers)
+	}
+	specs := ParseAccept(acceptLanguage)
+	return best(offers, func(offer string) float64 {
+		tag := strings.ToLower(offer)
+		q, specificity := -1.0, -1
+		for _, spec := range specs {
+			s := -1
+			switch {
+			case spec.Value == "*":
+				s = 0
+			case tag == spec.Value || strings.HasPrefix(tag, spec.Value+"-"):
+				s = len(spec.Value)
+			}
+			if s > specificity {
+				q, specificity = spec.Q, s
+			}
+		}
+		return q
+	})
+}
+
+// Charset returns the offered charset preferred by an Accept-Charset header.
+func Charset(acceptCharset string, offers []string) (string, bool) {
+	if strings.TrimSpace(acceptCharset) == "" {
+		return first(offers)
+	}
+	specs := ParseAccept(acceptCharset)
+	return best(offers, func(offer string) float64 {
+		q, specificity := -1.0, -1
+		for _, spec := range specs {
+			s := -1
+			switch {
+			case spec.Value == "*":
+				s = 0
+			case strings.EqualFold(spec.Value, offer):
+				s = 1
+			}
+			if s > specificity {
+				q, specificity = spec.Q, s
+			}
+		}
+		return q
+	})
+}
+
+// best returns the offer with the highest weight, ignoring offers that are
+// not matched or have a weight of zero.
+func best(offers []string, weight func(offer string) float64) (string, bool) {
+	bestOffer, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := weight(offer)
+		if q > bestQ {
+			bestOffer, bestQ = offer, q
+		}
+	}
+	return bestOffer, bestQ > 0
+}
+
+func first(offers []string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	return offers[0], true
+}
+
+// mediaRangeMatch returns how specifically spec matches the media type, or
+// -1 if it does not match at all.
+func mediaRangeMatch(spec Spec, offerType string, offerParams map[string]string) int {
+	rangeType, rangeSubtype, _ := strings.Cut(spec.Value, "/")
+	typ, subtype, _ := strings.Cut(offerType, "/")
+	switch {
+	case rangeType == "*" && rangeSubtype == "*":
+		return 0
+	case rangeType != typ:
+		return -1
+	case rangeSubtype == "*":
+		return 1
+	case rangeSubtype != subtype:
+		return -1
+	}
+	for name, value := range spec.Params {
+		if !strings.EqualFold(offerParams[name], value) {
+			return -1
+		}
+	}
+	return 2 + len(spec.Params)
+}
+
+func parseMediaType(mediaType string) (string, map[string]string) {
+	value, params, err := headers.ParseParameters(mediaType)
+	if err != nil {
+		return strings.ToLower(mediaType), nil
+	}
+	return strings.ToLower(value), params
+}
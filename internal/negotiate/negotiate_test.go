@@ -0,0 +1,90 @@
+package negotiate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/response"
+)
+
+func TestParseAccept(t *testing.T) {
+	specs := ParseAccept(`text/html;level=1, text/*;q=0.3, application/json;q=bad, */*;q=0.1;ext="a,b"`)
+	require.Len(t, specs, 3)
+	assert.Equal(t, Spec{Value: "text/html", Params: map[string]string{"level": "1"}, Q: 1}, specs[0])
+	assert.Equal(t, Spec{Value: "text/*", Q: 0.3}, specs[1])
+	assert.Equal(t, Spec{Value: "*/*", Q: 0.1}, specs[2])
+
+	// Test: Parameters without a value are kept
+	specs = ParseAccept(`text/html;level, text/plain;q=0.5`)
+	require.Len(t, specs, 2)
+	assert.Equal(t, Spec{Value: "text/html", Params: map[string]string{"level": ""}, Q: 1}, specs[0])
+}
+
+func TestContentType(t *testing.T) {
+	offers := []string{"text/html", "application/json"}
+
+	// Test: Missing header picks the first offer
+	ct, ok := ContentType("", offers)
+	assert.True(t, ok)
+	assert.Equal(t, "text/html", ct)
+
+	// Test: Weights decide
+	ct, ok = ContentType("text/html;q=0.5, application/json", offers)
+	assert.True(t, ok)
+	assert.Equal(t, "application/json", ct)
+
+	// Test: Accept extensions after the weight are not media type parameters
+	ct, ok = ContentType("text/html;q=0.5;ext=1, application/json;q=0.4", offers)
+	assert.True(t, ok)
+	assert.Equal(t, "text/html", ct)
+
+	// Test: Most specific range wins
+	ct, ok = ContentType("text/*;q=0, */*;q=0.8", offers)
+	assert.True(t, ok)
+	assert.Equal(t, "application/json", ct)
+
+	// Test: Wildcards keep the server order on ties
+	ct, ok = ContentType("*/*", offers)
+	assert.True(t, ok)
+	assert.Equal(t, "text/html", ct)
+
+	// Test: Parameters must match
+	ct, ok = ContentType("text/html;level=2, text/html;level=1;q=0.5", []string{"text/html;level=1"})
+	assert.True(t, ok)
+	assert.Equal(t, "text/html;level=1", ct)
+
+	// Test: Nothing acceptable
+	_, ok = ContentType("image/png", offers)
+	assert.False(t, ok)
+}
+
+func TestLanguageAndCharset(t *testing.T) {
+	lang, ok := Language("fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5", []string{"en-US", "fr", "de"})
+	assert.True(t, ok)
+	assert.Equal(t, "fr", lang)
+
+	lang, ok = Language("en", []string{"de", "en-GB"})
+	assert.True(t, ok)
+	assert.Equal(t, "en-GB", lang)
+
+	_, ok = Language("ja", []string{"de", "en"})
+	assert.False(t, ok)
+
+	charset, ok := Charset("iso-8859-5, UTF-8;q=0.8", []string{"utf-8"})
+	assert.True(t, ok)
+	assert.Equal(t, "utf-8", charset)
+
+	_, ok = Charset("*;q=0", []string{"utf-8"})
+	assert.False(t, ok)
+}
+
+func TestWriteNotAcceptable(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteNotAcceptable(response.NewWriter(buf), []string{"text/html", "application/json"}))
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 406 Not Acceptable\r\n"))
+	assert.True(t, strings.HasSuffix(buf.String(), "available: text/html, application/json\n"))
+}
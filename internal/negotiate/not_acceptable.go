@@ -0,0 +1,19 @@
+package negotiate
+
+import (
+	"strings"
+
+	"http/internal/response"
+)
+
+// WriteNotAcceptable answers with 406 Not Acceptable, listing the offers the
+// server could have produced.
+func WriteNotAcceptable(w *response.Writer, offers []string) error {
+	rw := response.NewResponseWriter(w)
+	rw.WriteHeader(response.StatusNotAcceptable)
+	_, err := rw.Write([]byte("Not Acceptable, available: " + strings.Join(offers, ", ") + "\n"))
+	if err != nil {
+		return err
+	}
+	return rw.Close()
+}
@@ -0,0 +1,77 @@
+package request
+
+import (
+	"bytes"
+	"io"
+)
+
+// BodyReader returns a reader over the request body. Requests parsed with
+// RequestFromReader already hold the whole body in Body.
+func (r *Request) BodyReader() io.Reader {
+	if r.body == nil {
+		return bytes.NewReader(r.Body)
+	}
+	return r.body
+}
+
+// SetBodyReader replaces the reader returned by BodyReader, letting callers
+// wrap the body with decoders or limits.
+func (r *Request) SetBodyReader(body io.Reader) {
+	r.body = body
+}
+
+// Buffered returns the bytes read from the connection while parsing the
+// headers that have not been consumed through the body reader yet. They
+// are the start of the unread body followed by anything the client sent
+// after the request.
+func (r *Request) Buffered() []byte {
+	if r.raw == nil {
+		return nil
+	}
+	return r.raw.buffered
+}
+
+// ReadBody reads the rest of the body into Body and returns it.
+func (r *Request) ReadBody() ([]byte, error) {
+	if r.body == nil {
+		return r.Body, nil
+	}
+	data, err := io.ReadAll(r.body)
+	r.Body = append(r.Body, data...)
+	if err != nil {
+		return r.Body, err
+	}
+	r.body = nil
+	r.state = requestStateDone
+	return r.Body, nil
+}
+
+type bodyReader struct {
+	buffered  []byte
+	src       io.Reader
+	remaining int64
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	if len(b.buffered) > 0 {
+		n := copy(p, b.buffered)
+		b.buffered = b.buffered[n:]
+		b.remaining -= int64(n)
+		return n, nil
+	}
+	n, err := b.src.Read(p)
+	b.remaining -= int64(n)
+	if err == io.EOF && b.remaining > 0 {
+		if n > 0 {
+			return n, nil
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,17 @@
+package request
+
+import "context"
+
+// Context returns the request's context. It is never nil.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// SetContext replaces the request's context, letting middlewares attach
+// values for the handlers they wrap.
+func (r *Request) SetContext(ctx context.Context) {
+	r.ctx = ctx
+}
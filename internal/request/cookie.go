@@ -0,0 +1,18 @@
+package request
+
+import "http/internal/headers"
+
+// Cookies parses the cookies sent in the request's Cookie header.
+func (r *Request) Cookies() []*headers.Cookie {
+	return headers.ParseCookies(r.Headers.Get("Cookie"))
+}
+
+// Cookie returns the first cookie with the given name.
+func (r *Request) Cookie(name string) (*headers.Cookie, bool) {
+	for _, c := range r.Cookies() {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
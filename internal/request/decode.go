@@ -0,0 +1,100 @@
+package request
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"http/internal/headers"
+)
+
+var (
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+	ErrBodyTooLarge        = errors.New("request body too large")
+)
+
+// DecodeBody makes BodyReader return the body with the codings listed in
+// Content-Encoding removed. At most maxSize decoded bytes are returned before
+// reads fail with ErrBodyTooLarge, guarding against compression bombs. The
+// Content-Encoding and Content-Length headers are removed since they no
+// longer describe the body. Bodies without a content coding are left as
+// they are.
+func (r *Request) DecodeBody(maxSize int64) error {
+	var codings []string
+	for _, coding := range headers.SplitList(r.Headers.Get("Content-Encoding")) {
+		coding = strings.ToLower(coding)
+		switch coding {
+		case "identity":
+		case "gzip", "x-gzip", "deflate":
+			codings = append(codings, coding)
+		default:
+			return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, coding)
+		}
+	}
+	body := r.BodyReader()
+	// codings are listed in the order they were applied
+	for i := len(codings) - 1; i >= 0; i-- {
+		body = &decodeReader{src: body, coding: codings[i]}
+	}
+	if len(codings) == 0 {
+		return nil
+	}
+	r.Headers.Remove("Content-Encoding")
+	r.Headers.Remove("Content-Length")
+	r.SetBodyReader(&limitReader{r: body, remaining: maxSize})
+	return nil
+}
+
+// decodeReader creates its decompressor on the first Read, so that nothing
+// is read from the connection until the handler asks for the body.
+type decodeReader struct {
+	src     io.Reader
+	coding  string
+	decoder io.Reader
+}
+
+func (d *decodeReader) Read(p []byte) (int, error) {
+	if d.decoder == nil {
+		var err error
+		switch d.coding {
+		case "gzip", "x-gzip":
+			d.decoder, err = gzip.NewReader(d.src)
+		case "deflate":
+			d.decoder, err = zlib.NewReader(d.src)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return d.decoder.Read(p)
+}
+
+// LimitReader returns a reader returning at most n bytes from r, after which
+// reads fail with ErrBodyTooLarge rather than io.EOF, so that an oversized
+// body is not mistaken for a complete one.
+func LimitReader(r io.Reader, n int64) io.Reader {
+	return &limitReader{r: r, remaining: n}
+}
+
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrBodyTooLarge
+	}
+	return n, err
+}
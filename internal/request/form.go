@@ -0,0 +1,68 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+)
+
+var ErrNotForm = errors.New("request body is not a form")
+
+// Values maps form field names to their values, in the order they were sent.
+type Values map[string][]string
+
+// Get returns the first value of key, or "" if there is none.
+func (v Values) Get(key string) string {
+	if vs := v[key]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+func (v Values) Add(key, value string) {
+	v[key] = append(v[key], value)
+}
+
+func (v Values) Set(key, value string) {
+	v[key] = []string{value}
+}
+
+func (v Values) Del(key string) {
+	delete(v, key)
+}
+
+func (v Values) Has(key string) bool {
+	_, ok := v[key]
+	return ok
+}
+
+// ParseQuery parses an application/x-www-form-urlencoded string such as a
+// query string or form body.
+func ParseQuery(query string) (Values, error) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return Values(values), nil
+}
+
+// Query parses the query string of the request target.
+func (r *Request) Query() (Values, error) {
+	_, query, _ := strings.Cut(r.RequestLine.RequestTarget, "?")
+	return ParseQuery(query)
+}
+
+// ParseForm reads and parses an application/x-www-form-urlencoded body of
+// at most maxSize bytes. It returns ErrNotForm for other content types.
+func (r *Request) ParseForm(maxSize int64) (Values, error) {
+	mediaType, _ := r.Headers.ContentType()
+	if mediaType != "application/x-www-form-urlencoded" {
+		return nil, ErrNotForm
+	}
+	data, err := io.ReadAll(&limitReader{r: r.BodyReader(), remaining: maxSize})
+	if err != nil {
+		return nil, err
+	}
+	return ParseQuery(string(data))
+}
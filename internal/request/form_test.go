@@ -0,0 +1,193 @@
+package request
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func formRequest(t *testing.T, contentType, body string) *Request {
+	t.Helper()
+	r, err := RequestHeadersFromReader(&chunkReader{
+		data: "POST /upload?x=1&x=2 HTTP/1.1\r\n" +
+			"Content-Type: " + contentType + "\r\n" +
+			"Content-Length: " + strconv.Itoa(len(body)) + "\r\n" +
+			"\r\n" + body,
+		numBytesPerRead: 7,
+	})
+	require.NoError(t, err)
+	return r
+}
+
+func TestParseForm(t *testing.T) {
+	// Test: Multi valued fields and escapes
+	r := formRequest(t, "application/x-www-form-urlencoded", "name=J%C3%B6rg+M&tag=a&tag=b&empty=")
+	form, err := r.ParseForm(1024)
+	require.NoError(t, err)
+	assert.Equal(t, "Jörg M", form.Get("name"))
+	assert.Equal(t, []string{"a", "b"}, form["tag"])
+	assert.True(t, form.Has("empty"))
+	assert.Equal(t, "", form.Get("missing"))
+
+	// Test: Query string
+	query, err := r.Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, query["x"])
+
+	// Test: Size limit
+	r = formRequest(t, "application/x-www-form-urlencoded", "a="+strings.Repeat("x", 100))
+	_, err = r.ParseForm(10)
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Other content types
+	r = formRequest(t, "application/json", "{}")
+	_, err = r.ParseForm(1024)
+	require.ErrorIs(t, err, ErrNotForm)
+
+	// Test: Invalid escape
+	r = formRequest(t, "application/x-www-form-urlencoded", "a=%zz")
+	_, err = r.ParseForm(1024)
+	require.Error(t, err)
+}
+
+const multipartBody = "preamble\r\n" +
+	"--XyZ\r\n" +
+	"Content-Disposition: form-data; name=\"title\"\r\n" +
+	"\r\n" +
+	"hello\r\nworld\r\n" +
+	"--XyZ \r\n" +
+	"Content-Disposition: form-data; name=\"file\"; filename=\"C:\\\\dir\\\\a.txt\"\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"--XyZ is not a boundary here\r\n" +
+	"--XyZ\r\n" +
+	"Content-Disposition: form-data; name=\"file\"; filename*=UTF-8''%C3%A9t%C3%A9.bin\r\n" +
+	"\r\n" +
+	"0123456789abcdef\r\n" +
+	"--XyZ--\r\n" +
+	"epilogue"
+
+func TestMultipartReader(t *testing.T) {
+	r := formRequest(t, `multipart/form-data; boundary="XyZ"`, multipartBody)
+	mr, err := r.MultipartReader()
+	require.NoError(t, err)
+
+	// Test: Part headers and body
+	p, err := mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "title", p.FormName())
+	assert.Equal(t, "", p.FileName())
+	data, err := io.ReadAll(p)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\r\nworld", string(data))
+
+	// Test: Directory components are stripped from file names
+	p, err = mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "file", p.FormName())
+	assert.Equal(t, "a.txt", p.FileName())
+	assert.Equal(t, "text/plain", p.Headers.Get("Content-Type"))
+
+	// Test: Unread parts are skipped
+	p, err = mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "été.bin", p.FileName())
+	buf := make([]byte, 3)
+	n, err := p.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "012", string(buf[:n]))
+
+	_, err = mr.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = mr.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Not multipart or no boundary
+	_, err = formRequest(t, "text/plain", "").MultipartReader()
+	require.ErrorIs(t, err, ErrNotMultipart)
+	_, err = formRequest(t, "multipart/form-data", "").MultipartReader()
+	require.ErrorIs(t, err, ErrInvalidBoundary)
+
+	// Test: Missing closing boundary
+	mr, err = formRequest(t, "multipart/form-data; boundary=b",
+		"--b\r\nContent-Disposition: form-data; name=a\r\n\r\ntruncated").MultipartReader()
+	require.NoError(t, err)
+	p, err = mr.NextPart()
+	require.NoError(t, err)
+	_, err = io.ReadAll(p)
+	require.ErrorIs(t, err, ErrMultipartTruncated)
+
+	// Test: Part count limit
+	mr, err = formRequest(t, "multipart/form-data; boundary=b",
+		strings.Repeat("--b\r\n\r\nx\r\n", 3)+"--b--").MultipartReader()
+	require.NoError(t, err)
+	mr.MaxParts = 2
+	for range 2 {
+		_, err = mr.NextPart()
+		require.NoError(t, err)
+	}
+	_, err = mr.NextPart()
+	require.ErrorIs(t, err, ErrTooManyParts)
+
+	// Test: Part header size limit
+	mr, err = formRequest(t, "multipart/form-data; boundary=b",
+		"--b\r\nX-Long: "+strings.Repeat("x", 100)+"\r\n\r\n\r\n--b--").MultipartReader()
+	require.NoError(t, err)
+	mr.MaxHeaderSize = 64
+	_, err = mr.NextPart()
+	require.ErrorIs(t, err, ErrPartHeaderTooLarge)
+}
+
+func TestParseMultipartForm(t *testing.T) {
+	limits := DefaultMultipartLimits()
+	limits.MaxMemory = 30
+
+	// Test: Values, in memory files and spooled files
+	r := formRequest(t, `multipart/form-data; boundary=XyZ`, multipartBody)
+	form, err := r.ParseMultipartForm(limits)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\r\nworld", form.Value.Get("title"))
+	files := form.File["file"]
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "a.txt", files[0].Filename)
+	assert.Equal(t, int64(28), files[0].Size)
+	assert.Empty(t, files[0].tmpfile)
+	f, err := files[0].Open()
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "--XyZ is not a boundary here", string(data))
+
+	assert.Equal(t, int64(16), files[1].Size)
+	require.NotEmpty(t, files[1].tmpfile)
+	f, err = files[1].Open()
+	require.NoError(t, err)
+	data, err = io.ReadAll(f)
+	require.NoError(t, err)
+	f.Close()
+	assert.Equal(t, "0123456789abcdef", string(data))
+
+	require.NoError(t, form.RemoveAll())
+	_, err = os.Stat(files[1].tmpfile)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Test: Total size limit
+	limits.MaxSize = 20
+	r = formRequest(t, `multipart/form-data; boundary=XyZ`, multipartBody)
+	_, err = r.ParseMultipartForm(limits)
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Skipped parts without a form name count against the size limit
+	r = formRequest(t, `multipart/form-data; boundary=XyZ`, "--XyZ\r\n"+
+		"Content-Disposition: attachment\r\n\r\n"+
+		strings.Repeat("x", 100)+"\r\n"+
+		"--XyZ--\r\n")
+	_, err = r.ParseMultipartForm(limits)
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+}
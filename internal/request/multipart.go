@@ -0,0 +1,426 @@
+package request
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"http/internal/headers"
+)
+
+const (
+	DefaultMaxParts      = 1000
+	DefaultMaxHeaderSize = 16 << 10
+	DefaultMaxMemory     = 10 << 20
+	DefaultMaxFormSize   = 64 << 20
+
+	multipartBufferSize = 64 << 10
+)
+
+var (
+	ErrNotMultipart       = errors.New("request body is not multipart/form-data")
+	ErrInvalidBoundary    = errors.New("invalid multipart boundary")
+	ErrMalformedMultipart = errors.New("malformed multipart body")
+	ErrTooManyParts       = errors.New("too many multipart parts")
+	ErrPartHeaderTooLarge = errors.New("multipart part header too large")
+	ErrMultipartTruncated = errors.New("multipart body ended before the closing boundary")
+)
+
+// MultipartReader streams the parts of a multipart/form-data body. Each
+// part must be read before the next one is requested; unread data is
+// skipped by NextPart.
+type MultipartReader struct {
+	// MaxParts is the largest number of parts NextPart returns before
+	// failing with ErrTooManyParts.
+	MaxParts int
+	// MaxHeaderSize limits the size of a part's header section.
+	MaxHeaderSize int
+
+	br      *bufio.Reader
+	delim   []byte
+	parts   int
+	current *Part
+	started bool
+	err     error
+}
+
+// Part is one part of a multipart body. Reading it returns the part's body.
+type Part struct {
+	Headers headers.Headers
+
+	mr  *MultipartReader
+	eof bool
+}
+
+// MultipartReader returns a reader for a multipart/form-data body.
+func (r *Request) MultipartReader() (*MultipartReader, error) {
+	mediaType, params := r.Headers.ContentType()
+	if mediaType != "multipart/form-data" {
+		return nil, ErrNotMultipart
+	}
+	boundary := params["boundary"]
+	if len(boundary) == 0 || len(boundary) > 70 || strings.HasSuffix(boundary, " ") {
+		return nil, ErrInvalidBoundary
+	}
+	return NewMultipartReader(r.BodyReader(), boundary), nil
+}
+
+func NewMultipartReader(body io.Reader, boundary string) *MultipartReader {
+	return &MultipartReader{
+		MaxParts:      DefaultMaxParts,
+		MaxHeaderSize: DefaultMaxHeaderSize,
+		br:            bufio.NewReaderSize(body, multipartBufferSize),
+		delim:         []byte("\r\n--" + boundary),
+	}
+}
+
+// NextPart returns the next part, or io.EOF after the closing boundary.
+func (mr *MultipartReader) NextPart() (*Part, error) {
+	if mr.err != nil {
+		return nil, mr.err
+	}
+	p, err := mr.nextPart()
+	if err != nil {
+		mr.err = err
+		return nil, err
+	}
+	return p, nil
+}
+
+func (mr *MultipartReader) nextPart() (*Part, error) {
+	if !mr.started {
+		if err := mr.skipPreamble(); err != nil {
+			return nil, err
+		}
+		mr.started = true
+	} else {
+		if _, err := io.Copy(io.Discard, mr.current); err != nil {
+			return nil, err
+		}
+		if _, err := mr.br.Discard(len(mr.delim)); err != nil {
+			return nil, err
+		}
+	}
+
+	// the rest of the boundary line is "--" for the closing boundary, or
+	// optional transport padding
+	line, err := mr.readLine()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	rest := bytes.TrimRight(line, " \t\r\n")
+	if bytes.Equal(rest, []byte("--")) {
+		return nil, io.EOF
+	}
+	if len(rest) != 0 || err != nil {
+		return nil, ErrMalformedMultipart
+	}
+
+	mr.parts++
+	if mr.parts > mr.MaxParts {
+		return nil, ErrTooManyParts
+	}
+	h, err := mr.readHeaders()
+	if err != nil {
+		return nil, err
+	}
+	mr.current = &Part{Headers: h, mr: mr}
+	return mr.current, nil
+}
+
+// skipPreamble discards everything up to the first dash-boundary, leaving
+// the reader positioned right after it.
+func (mr *MultipartReader) skipPreamble() error {
+	dashBoundary := mr.delim[2:]
+	for {
+		// a line longer than the buffer cannot be a boundary
+		peek, err := mr.br.Peek(len(dashBoundary))
+		if err == nil && bytes.Equal(peek, dashBoundary) {
+			_, err = mr.br.Discard(len(dashBoundary))
+			return err
+		}
+		if errors.Is(err, io.EOF) {
+			return ErrMultipartTruncated
+		}
+		if err != nil {
+			return err
+		}
+		for {
+			_, err = mr.br.ReadSlice('\n')
+			if !errors.Is(err, bufio.ErrBufferFull) {
+				break
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return ErrMultipartTruncated
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readLine reads a line including its line ending, failing with
+// ErrPartHeaderTooLarge if it is longer than MaxHeaderSize.
+func (mr *MultipartReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := mr.br.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > mr.MaxHeaderSize {
+			return nil, ErrPartHeaderTooLarge
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, err
+		}
+	}
+}
+
+func (mr *MultipartReader) readHeaders() (headers.Headers, error) {
+	var block []byte
+	for {
+		line, err := mr.readLine()
+		if errors.Is(err, io.EOF) {
+			return nil, ErrMultipartTruncated
+		}
+		if err != nil {
+			return nil, err
+		}
+		block = append(block, line...)
+		if len(block) > mr.MaxHeaderSize {
+			return nil, ErrPartHeaderTooLarge
+		}
+		if bytes.Equal(line, []byte("\r\n")) {
+			break
+		}
+	}
+	h := headers.NewHeaders()
+	for {
+		n, done, err := h.Parse(block)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrMalformedMultipart, err)
+		}
+		if done {
+			return h, nil
+		}
+		if n == 0 {
+			return nil, ErrMalformedMultipart
+		}
+		block = block[n:]
+	}
+}
+
+// Read reads the part's body up to the next boundary.
+func (p *Part) Read(b []byte) (int, error) {
+	if p.eof {
+		return 0, io.EOF
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	mr := p.mr
+	peek, err := mr.br.Peek(min(len(b)+len(mr.delim), mr.br.Size()))
+	if idx := bytes.Index(peek, mr.delim); idx >= 0 {
+		if idx == 0 {
+			p.eof = true
+			return 0, io.EOF
+		}
+		n := copy(b, peek[:idx])
+		mr.br.Discard(n)
+		return n, nil
+	}
+	if errors.Is(err, io.EOF) {
+		return 0, ErrMultipartTruncated
+	}
+	if err != nil {
+		return 0, err
+	}
+	// the tail of peek may be the start of a delimiter
+	n := copy(b, peek[:len(peek)-len(mr.delim)+1])
+	mr.br.Discard(n)
+	return n, nil
+}
+
+// FormName returns the name parameter of a form-data Content-Disposition.
+func (p *Part) FormName() string {
+	disposition, params := p.disposition()
+	if disposition != "form-data" {
+		return ""
+	}
+	return params["name"]
+}
+
+// FileName returns the file name of a file upload, without any directory
+// components the client may have sent.
+func (p *Part) FileName() string {
+	_, params := p.disposition()
+	name := params["filename"]
+	if ext, ok := params["filename*"]; ok {
+		// RFC 8187 ext-value: charset'language'percent-encoded
+		parts := strings.SplitN(ext, "'", 3)
+		if len(parts) == 3 && strings.EqualFold(parts[0], "utf-8") {
+			if decoded, err := url.PathUnescape(parts[2]); err == nil {
+				name = decoded
+			}
+		}
+	}
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func (p *Part) disposition() (string, map[string]string) {
+	value, params, err := headers.ParseParameters(p.Headers.Get("Content-Disposition"))
+	if err != nil {
+		return "", nil
+	}
+	return strings.ToLower(value), params
+}
+
+// MultipartLimits bounds the resources used by ParseMultipartForm.
+type MultipartLimits struct {
+	// MaxParts is the largest number of parts accepted.
+	MaxParts int
+	// MaxSize is the largest total size of all part bodies.
+	MaxSize int64
+	// MaxMemory is how many bytes of file uploads are kept in memory.
+	// Files that do not fit are spooled to temporary files.
+	MaxMemory int64
+}
+
+func DefaultMultipartLimits() MultipartLimits {
+	return MultipartLimits{
+		MaxParts:  DefaultMaxParts,
+		MaxSize:   DefaultMaxFormSize,
+		MaxMemory: DefaultMaxMemory,
+	}
+}
+
+// MultipartForm is a parsed multipart/form-data body. RemoveAll must be
+// called once the files are no longer needed.
+type MultipartForm struct {
+	Value Values
+	File  map[string][]*FileHeader
+}
+
+// FileHeader describes an uploaded file.
+type FileHeader struct {
+	Filename string
+	Headers  headers.Headers
+	Size     int64
+
+	content []byte
+	tmpfile string
+}
+
+// Open returns the file's content.
+func (fh *FileHeader) Open() (io.ReadCloser, error) {
+	if fh.tmpfile != "" {
+		return os.Open(fh.tmpfile)
+	}
+	return io.NopCloser(bytes.NewReader(fh.content)), nil
+}
+
+// RemoveAll deletes the temporary files holding spooled uploads.
+func (f *MultipartForm) RemoveAll() error {
+	var errs []error
+	for _, fhs := range f.File {
+		for _, fh := range fhs {
+			if fh.tmpfile == "" {
+				continue
+			}
+			if err := os.Remove(fh.tmpfile); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParseMultipartForm reads a whole multipart/form-data body within the
+// given limits. Parts without a form name are skipped.
+func (r *Request) ParseMultipartForm(limits MultipartLimits) (*MultipartForm, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	mr.MaxParts = limits.MaxParts
+	form := &MultipartForm{Value: Values{}, File: map[string][]*FileHeader{}}
+	remaining, memory := limits.MaxSize, limits.MaxMemory
+	for {
+		p, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return form, nil
+		}
+		if err != nil {
+			form.RemoveAll()
+			return nil, err
+		}
+		body := &limitReader{r: p, remaining: remaining}
+		name := p.FormName()
+		if name == "" {
+			// skipped parts still count against the size limit
+			if _, err := io.Copy(io.Discard, body); err != nil {
+				form.RemoveAll()
+				return nil, err
+			}
+			remaining = body.remaining
+			continue
+		}
+		filename := p.FileName()
+		if filename == "" {
+			data, err := io.ReadAll(body)
+			if err != nil {
+				form.RemoveAll()
+				return nil, err
+			}
+			form.Value.Add(name, string(data))
+		} else {
+			fh, err := readFile(p, body, filename, memory)
+			if err != nil {
+				form.RemoveAll()
+				return nil, err
+			}
+			if fh.tmpfile == "" {
+				memory -= fh.Size
+			}
+			form.File[name] = append(form.File[name], fh)
+		}
+		remaining = body.remaining
+	}
+}
+
+// readFile keeps the part in memory if it fits in memory bytes and spools it
+// to a temporary file otherwise.
+func readFile(p *Part, body io.Reader, filename string, memory int64) (*FileHeader, error) {
+	fh := &FileHeader{Filename: filename, Headers: p.Headers}
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, body, max(memory, 0)+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if n <= memory {
+		fh.content = buf.Bytes()
+		fh.Size = n
+		return fh, nil
+	}
+	f, err := os.CreateTemp("", "multipart-")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fh.tmpfile = f.Name()
+	size, err := io.Copy(f, io.MultiReader(&buf, body))
+	if err != nil {
+		os.Remove(fh.tmpfile)
+		return nil, err
+	}
+	fh.Size = size
+	return fh, nil
+}
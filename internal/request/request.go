@@ -2,10 +2,10 @@ package request
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
 
 	"http/internal/headers"
@@ -33,11 +33,21 @@ const (
 )
 
 type Request struct {
-	RequestLine    RequestLine
-	Headers        headers.Headers
-	Body           []byte
+	RequestLine RequestLine
+	Headers     headers.Headers
+	// Body holds the body of requests parsed with RequestFromReader. The
+	// server reads bodies lazily, so Body stays empty until the handler calls
+	// ReadBody; BodyReader streams it instead.
+	Body []byte
+	// Leniencies records the deviations from the strict grammar accepted
+	// while parsing in lenient mode.
+	Leniencies     headers.Leniency
 	state          state
-	bodyLengthRead int
+	mode           headers.Mode
+	bodyLengthRead int64
+	body           io.Reader
+	raw            *bodyReader
+	ctx            context.Context
 }
 
 type RequestLine struct {
@@ -47,14 +57,50 @@ type RequestLine struct {
 }
 
 func RequestFromReader(reader io.Reader) (*Request, error) {
+	r, _, err := readRequest(reader, requestStateDone, headers.Strict)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RequestHeadersFromReader parses the request line and headers but leaves the
+// body on the reader. The body is read on demand through BodyReader.
+func RequestHeadersFromReader(reader io.Reader) (*Request, error) {
+	return RequestHeadersFromReaderMode(reader, headers.Strict)
+}
+
+// RequestHeadersFromReaderMode is like RequestHeadersFromReader but parses
+// the request line and headers in the given mode. Leniencies applied in
+// headers.Lenient mode are recorded in the request's Leniencies.
+func RequestHeadersFromReaderMode(reader io.Reader, mode headers.Mode) (*Request, error) {
+	r, buffered, err := readRequest(reader, requestStateParsingBody, mode)
+	if err != nil {
+		return nil, err
+	}
+	conLen, _, err := r.Headers.ContentLength()
+	if err != nil {
+		return nil, errors.New("malformed content length header value")
+	}
+	r.raw = &bodyReader{
+		buffered:  buffered,
+		src:       reader,
+		remaining: conLen,
+	}
+	r.body = r.raw
+	return r, nil
+}
+
+func readRequest(reader io.Reader, until state, mode headers.Mode) (*Request, []byte, error) {
 	buf := make([]byte, bufferSize, bufferSize)
 	readToIndex := 0
 	r := &Request{
 		state:   requestStateInitialized,
+		mode:    mode,
 		Headers: headers.NewHeaders(),
 		Body:    []byte{},
 	}
-	for r.state != requestStateDone {
+	for r.state != until {
 		if readToIndex >= len(buf) {
 			tempBuf := make([]byte, 2*len(buf), 2*len(buf))
 			copy(tempBuf, buf)
@@ -63,39 +109,46 @@ func RequestFromReader(reader io.Reader) (*Request, error) {
 		nBytesRead, err := reader.Read(buf[readToIndex:])
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				if r.state != requestStateDone {
-					return nil, fmt.Errorf(
+				if r.state != until {
+					return nil, nil, fmt.Errorf(
 						"incomplete request, in state: %d, read n bytes on EOF: %d",
 						r.state, nBytesRead)
 				}
 				break
 			}
-			return nil, err
+			return nil, nil, err
 		}
 		readToIndex += nBytesRead
-		nBytesParsed, err := r.parse(buf[:readToIndex])
+		nBytesParsed, err := r.parse(buf[:readToIndex], until)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		copy(buf, buf[nBytesParsed:])
 		readToIndex -= nBytesParsed
 	}
 
-	return r, nil
+	return r, buf[:readToIndex], nil
 }
 
-func parseRequestLine(data []byte) (*RequestLine, int, error) {
+func parseRequestLine(data []byte, mode headers.Mode) (*RequestLine, int, headers.Leniency, error) {
 	idx := bytes.Index(data, []byte(crlf))
+	n := idx + 2
+	var applied headers.Leniency
+	if mode == headers.Lenient {
+		if lf := bytes.IndexByte(data, '\n'); lf != -1 && (idx == -1 || lf < idx+1) {
+			idx, n, applied = lf, lf+1, headers.LenientBareLF
+		}
+	}
 	if idx == -1 {
 		// No CRLF found, we need to read more data
-		return nil, 0, nil
+		return nil, 0, 0, nil
 	}
 	requestLineText := string(data[:idx])
 	requestLine, err := requestLineFromString(requestLineText)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, 0, err
 	}
-	return requestLine, idx + 2, nil
+	return requestLine, n, applied, nil
 }
 
 func requestLineFromString(str string) (*RequestLine, error) {
@@ -120,9 +173,9 @@ func requestLineFromString(str string) (*RequestLine, error) {
 	}, nil
 }
 
-func (r *Request) parse(data []byte) (int, error) {
+func (r *Request) parse(data []byte, until state) (int, error) {
 	totalBytesParsed := 0
-	for r.state != requestStateDone {
+	for r.state != until && r.state != requestStateDone {
 		n, err := r.parseSingle(data[totalBytesParsed:])
 		if err != nil {
 			return 0, err
@@ -138,7 +191,7 @@ func (r *Request) parse(data []byte) (int, error) {
 func (r *Request) parseSingle(data []byte) (int, error) {
 	switch r.state {
 	case requestStateInitialized:
-		requestLine, n, err := parseRequestLine(data)
+		requestLine, n, applied, err := parseRequestLine(data, r.mode)
 		if err != nil {
 			return 0, err
 		}
@@ -146,31 +199,33 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 			return 0, nil
 		}
 		r.RequestLine = *requestLine
+		r.Leniencies |= applied
 		r.state = requestStateParsingHeaders
 		return n, nil
 	case requestStateParsingHeaders:
-		n, done, err := r.Headers.Parse(data)
+		p := headers.Parser{Mode: r.mode}
+		n, done, err := p.Parse(r.Headers, data)
 		if err != nil {
 			return 0, err
 		}
+		r.Leniencies |= p.Applied
 		if done {
 			r.state = requestStateParsingBody
 		}
 		return n, nil
 	case requestStateParsingBody:
-		contentLength := r.Headers.Get("Content-Length")
-		if contentLength == "" {
+		conLen, ok, err := r.Headers.ContentLength()
+		if !ok {
 			r.state = requestStateDone
 			return len(data), nil
 		}
-		conLen, err := strconv.Atoi(contentLength)
 		if err != nil {
 			return 0, errors.New("malformed content length header value")
 		}
 		// remainingBytes := conLen - len(r.Body)
 		// r.Body = append(r.Body, data[:min(len(data), remainingBytes)]...)
 		r.Body = append(r.Body, data...)
-		r.bodyLengthRead += len(data)
+		r.bodyLengthRead += int64(len(data))
 		if r.bodyLengthRead > conLen {
 			return 0, errors.New("content len greater than reported")
 		} else if r.bodyLengthRead == conLen {
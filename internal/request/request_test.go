@@ -1,9 +1,15 @@
 package request
 
 import (
+	"bytes"
+	"compress/gzip"
 	"io"
+	"strconv"
+	"strings"
 	"testing"
 
+	"http/internal/headers"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -214,3 +220,159 @@ func TestRequestBody(t *testing.T) {
 	require.NotNil(t, r)
 	assert.Equal(t, "", string(r.Body))
 }
+
+func TestRequestHeadersFromReader(t *testing.T) {
+	// Test: Body is left unread until requested
+	reader := &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost\r\n" +
+			"Content-Length: 13\r\n" +
+			"\r\n" +
+			"hello world!\n",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestHeadersFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "", string(r.Body))
+	body, err := io.ReadAll(r.BodyReader())
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!\n", string(body))
+
+	// Test: ReadBody fills Body
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Content-Length: 5\r\n" +
+			"\r\n" +
+			"hello",
+		numBytesPerRead: 64,
+	}
+	r, err = RequestHeadersFromReader(reader)
+	require.NoError(t, err)
+	body, err = r.ReadBody()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, "hello", string(r.Body))
+
+	// Test: Body shorter than reported content length
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Content-Length: 20\r\n" +
+			"\r\n" +
+			"partial content",
+		numBytesPerRead: 3,
+	}
+	r, err = RequestHeadersFromReader(reader)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.BodyReader())
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Test: No content length means no body
+	reader = &chunkReader{
+		data: "GET / HTTP/1.1\r\n" +
+			"Host: localhost\r\n" +
+			"\r\n",
+		numBytesPerRead: 8,
+	}
+	r, err = RequestHeadersFromReader(reader)
+	require.NoError(t, err)
+	body, err = io.ReadAll(r.BodyReader())
+	require.NoError(t, err)
+	assert.Empty(t, body)
+}
+
+func gzipped(t *testing.T, s string) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	_, err := zw.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.String()
+}
+
+func TestLenientParsing(t *testing.T) {
+	data := "GET / HTTP/1.1\n" +
+		"Host: localhost\r\n" +
+		"X-Long: first\r\n" +
+		"\t second\n" +
+		"Accept: */*\n" +
+		"\n"
+
+	// Test: Strict mode rejects bare LF
+	_, err := RequestHeadersFromReader(&chunkReader{data: data, numBytesPerRead: 3})
+	require.Error(t, err)
+
+	// Test: Lenient mode unfolds and records leniencies
+	r, err := RequestHeadersFromReaderMode(&chunkReader{data: data, numBytesPerRead: 3}, headers.Lenient)
+	require.NoError(t, err)
+	assert.Equal(t, "/", r.RequestLine.RequestTarget)
+	assert.Equal(t, "first second", r.Headers.Get("X-Long"))
+	assert.Equal(t, "*/*", r.Headers.Get("Accept"))
+	assert.True(t, r.Leniencies.Has(headers.LenientObsFold|headers.LenientBareLF))
+
+	// Test: Well formed requests apply no leniency
+	r, err = RequestHeadersFromReaderMode(&chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n",
+		numBytesPerRead: 3,
+	}, headers.Lenient)
+	require.NoError(t, err)
+	assert.Zero(t, r.Leniencies)
+}
+
+func TestCookies(t *testing.T) {
+	r, err := RequestFromReader(&chunkReader{
+		data:            "GET / HTTP/1.1\r\nCookie: id=42; theme=\"dark\"\r\n\r\n",
+		numBytesPerRead: 5,
+	})
+	require.NoError(t, err)
+	cookies := r.Cookies()
+	require.Len(t, cookies, 2)
+	assert.Equal(t, "id", cookies[0].Name)
+	c, ok := r.Cookie("theme")
+	require.True(t, ok)
+	assert.Equal(t, "dark", c.Value)
+	_, ok = r.Cookie("missing")
+	assert.False(t, ok)
+}
+
+func TestDecodeBody(t *testing.T) {
+	// Test: gzip body is inflated
+	body := gzipped(t, "hello compressed world")
+	r, err := RequestHeadersFromReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nContent-Encoding: gzip\r\n" +
+			"Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body))
+	require.NoError(t, err)
+	require.NoError(t, r.DecodeBody(1024))
+	assert.Equal(t, "", r.Headers.Get("Content-Encoding"))
+	data, err := r.ReadBody()
+	require.NoError(t, err)
+	assert.Equal(t, "hello compressed world", string(data))
+
+	// Test: Decoded size limit
+	body = gzipped(t, strings.Repeat("a", 4096))
+	r, err = RequestHeadersFromReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nContent-Encoding: gzip\r\n" +
+			"Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body))
+	require.NoError(t, err)
+	require.NoError(t, r.DecodeBody(100))
+	data, err = io.ReadAll(r.BodyReader())
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+	assert.Len(t, data, 100)
+
+	// Test: Identity body is not limited
+	r, err = RequestHeadersFromReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nContent-Encoding: identity\r\nContent-Length: 5\r\n\r\nhello"))
+	require.NoError(t, err)
+	require.NoError(t, r.DecodeBody(2))
+	assert.Equal(t, "5", r.Headers.Get("Content-Length"))
+	data, err = io.ReadAll(r.BodyReader())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	// Test: Unknown encoding
+	r, err = RequestHeadersFromReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nContent-Encoding: br\r\nContent-Length: 0\r\n\r\n"))
+	require.NoError(t, err)
+	require.ErrorIs(t, r.DecodeBody(100), ErrUnsupportedEncoding)
+}
@@ -0,0 +1,28 @@
+package request
+
+import (
+	"strings"
+
+	"http/internal/headers"
+)
+
+// UpgradeProtocols returns the protocols listed in the Upgrade header, in the
+// client's order of preference. An Upgrade header only counts when the
+// Connection header names it too, so that intermediaries which do not
+// understand it drop it.
+func (r *Request) UpgradeProtocols() []string {
+	if !r.Headers.HasConnectionToken("upgrade") {
+		return nil
+	}
+	return headers.SplitList(r.Headers.Get("Upgrade"))
+}
+
+// OffersUpgrade reports whether the client offers to switch to protocol.
+func (r *Request) OffersUpgrade(protocol string) bool {
+	for _, p := range r.UpgradeProtocols() {
+		if strings.EqualFold(p, protocol) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package response
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"http/internal/headers"
+)
+
+const DefaultChunkSize = 32 * 1024
+
+var (
+	ErrInvalidExtension = errors.New("invalid chunk extension")
+	ErrWriterClosed     = errors.New("write on closed chunked writer")
+)
+
+// ChunkedWriter streams a body using chunked transfer coding. Each Write is
+// sent as one or more chunks of at most the configured chunk size and Close
+// writes the last chunk followed by any trailers.
+type ChunkedWriter struct {
+	w          *Writer
+	chunkSize  int
+	extensions string
+	trailer    headers.Headers
+	closed     bool
+}
+
+// NewChunkedWriter writes h as the response headers, marking the response as
+// chunked, and returns a writer for the body. Trailers that may be sent on
+// Close must be listed in the Trailer header of h.
+func NewChunkedWriter(w *Writer, h headers.Headers) (*ChunkedWriter, error) {
+	h.Remove("Content-Length")
+	h.Override("Transfer-Encoding", "chunked")
+	if err := w.WriteHeaders(h); err != nil {
+		return nil, err
+	}
+	return &ChunkedWriter{
+		w:         w,
+		chunkSize: DefaultChunkSize,
+		trailer:   headers.NewHeaders(),
+	}, nil
+}
+
+// SetChunkSize sets the largest chunk the writer will send.
+func (cw *ChunkedWriter) SetChunkSize(n int) {
+	if n <= 0 {
+		n = DefaultChunkSize
+	}
+	cw.chunkSize = n
+}
+
+// SetExtension adds a chunk extension sent with every following chunk. An
+// empty value sends the extension name alone.
+func (cw *ChunkedWriter) SetExtension(name, value string) error {
+	if !headers.ValidFieldName(name) {
+		return ErrInvalidExtension
+	}
+	ext := ";" + name
+	if value != "" {
+		if headers.ValidFieldName(value) {
+			ext += "=" + value
+		} else {
+			if strings.ContainsAny(value, "\r\n") {
+				return ErrInvalidExtension
+			}
+			ext += "=" + headers.Quote(value)
+		}
+	}
+	cw.extensions += ext
+	return nil
+}
+
+// Trailer returns the trailer fields sent by Close.
+func (cw *ChunkedWriter) Trailer() headers.Headers {
+	return cw.trailer
+}
+
+func (cw *ChunkedWriter) Write(p []byte) (int, error) {
+	if cw.closed {
+		return 0, ErrWriterClosed
+	}
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), cw.chunkSize)]
+		if err := cw.writeChunk(chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// ReadFrom copies r to the body, one chunk per read.
+func (cw *ChunkedWriter) ReadFrom(r io.Reader) (int64, error) {
+	if cw.closed {
+		return 0, ErrWriterClosed
+	}
+	buf := make([]byte, cw.chunkSize)
+	var total int64
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if werr := cw.writeChunk(buf[:n]); werr != nil {
+				return total, werr
+			}
+			total += int64(n)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return total, nil
+			}
+			return total, err
+		}
+	}
+}
+
+// Close writes the last chunk and the trailers. Trailer fields that are not
+// allowed return an error without ending the body.
+func (cw *ChunkedWriter) Close() error {
+	if cw.closed {
+		return nil
+	}
+	_, err := cw.w.WriteChunkedBodyDone(cw.trailer)
+	if err != nil {
+		return err
+	}
+	cw.closed = true
+	return nil
+}
+
+func (cw *ChunkedWriter) writeChunk(p []byte) error {
+	_, err := cw.w.writeChunkData(p, cw.extensions)
+	return err
+}
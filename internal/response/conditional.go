@@ -0,0 +1,143 @@
+package response
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"http/internal/headers"
+	"http/internal/request"
+)
+
+// StrongETag returns a strong entity tag derived from the content bytes.
+func StrongETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// WeakETag returns a weak entity tag for content identified by its size and
+// modification time, such as a file.
+func WeakETag(size int64, modtime time.Time) string {
+	return fmt.Sprintf(`W/"%x-%x"`, modtime.Unix(), size)
+}
+
+// SetLastModified sets the Last-Modified header to modtime, truncated to the
+// second precision of HTTP dates.
+func SetLastModified(h headers.Headers, modtime time.Time) {
+	if modtime.IsZero() {
+		return
+	}
+	h.SetTime("Last-Modified", modtime)
+}
+
+// CheckPreconditions evaluates the conditional headers of r against the ETag
+// and Last-Modified validators in h, following the precedence of RFC 9110
+// section 13.2.2. When a condition fails it writes 304 Not Modified or 412
+// Precondition Failed and reports done; otherwise nothing is written and the
+// handler should send the full response.
+func CheckPreconditions(w *Writer, r *request.Request, h headers.Headers) (done bool, err error) {
+	etag := h.Get("ETag")
+	lastModified, hasLastModified := parseHTTPDate(h.Get("Last-Modified"))
+	method := r.RequestLine.Method
+
+	if ifMatch := r.Headers.Get("If-Match"); ifMatch != "" {
+		if !etagListMatches(ifMatch, etag, true) {
+			return true, writePreconditionFailed(w, h)
+		}
+	} else if since, ok := parseHTTPDate(r.Headers.Get("If-Unmodified-Since")); ok && hasLastModified {
+		if lastModified.After(since) {
+			return true, writePreconditionFailed(w, h)
+		}
+	}
+
+	if ifNoneMatch := r.Headers.Get("If-None-Match"); ifNoneMatch != "" {
+		if etagListMatches(ifNoneMatch, etag, false) {
+			if method == "GET" || method == "HEAD" {
+				return true, writeNotModified(w, h)
+			}
+			return true, writePreconditionFailed(w, h)
+		}
+	} else if since, ok := parseHTTPDate(r.Headers.Get("If-Modified-Since")); ok && hasLastModified {
+		if (method == "GET" || method == "HEAD") && !lastModified.After(since) {
+			return true, writeNotModified(w, h)
+		}
+	}
+	return false, nil
+}
+
+func writeNotModified(w *Writer, h headers.Headers) error {
+	h.Remove("Content-Type")
+	h.Remove("Content-Length")
+	h.Remove("Transfer-Encoding")
+	if err := w.WriteStatusLine(StatusNotModified); err != nil {
+		return err
+	}
+	return w.WriteHeaders(h)
+}
+
+func writePreconditionFailed(w *Writer, h headers.Headers) error {
+	ph := headers.NewHeaders()
+	ph.SetContentLength(0)
+	if connection := h.Get("Connection"); connection != "" {
+		ph.Set("Connection", connection)
+	}
+	if err := w.WriteStatusLine(StatusPreconditionFailed); err != nil {
+		return err
+	}
+	return w.WriteHeaders(ph)
+}
+
+// etagListMatches reports whether etag is in the If-Match or If-None-Match
+// list. "*" matches any current representation. If-Match uses the strong
+// comparison and If-None-Match the weak one.
+func etagListMatches(list, etag string, strong bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range splitETags(list) {
+		if strong {
+			if !isWeak(candidate) && !isWeak(etag) && candidate == etag {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// splitETags splits a comma separated list of entity tags, which may contain
+// commas inside their quoted part. Elements that are not entity tags are
+// skipped.
+func splitETags(list string) []string {
+	var tags []string
+	for _, tag := range headers.SplitList(list) {
+		opaque := strings.TrimPrefix(tag, "W/")
+		if len(opaque) >= 2 && opaque[0] == '"' && opaque[len(opaque)-1] == '"' {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func isWeak(etag string) bool {
+	return strings.HasPrefix(etag, "W/")
+}
+
+func parseHTTPDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := headers.ParseHTTPDate(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
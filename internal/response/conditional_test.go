@@ -0,0 +1,98 @@
+package response
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/headers"
+	"http/internal/request"
+)
+
+func checkPreconditions(t *testing.T, req string, h headers.Headers) (bool, string) {
+	t.Helper()
+	r, err := request.RequestFromReader(strings.NewReader(req))
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	done, err := CheckPreconditions(w, r, h)
+	require.NoError(t, err)
+	return done, buf.String()
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	modtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	validators := func() headers.Headers {
+		h := headers.NewHeaders()
+		h.Set("ETag", `"abc"`)
+		h.Set("Content-Type", "text/plain")
+		SetLastModified(h, modtime)
+		return h
+	}
+
+	// Test: No conditional headers
+	done, out := checkPreconditions(t, "GET / HTTP/1.1\r\n\r\n", validators())
+	assert.False(t, done)
+	assert.Empty(t, out)
+
+	// Test: If-None-Match hit on GET
+	done, out = checkPreconditions(t, "GET / HTTP/1.1\r\nIf-None-Match: \"xyz\", W/\"abc\"\r\n\r\n", validators())
+	assert.True(t, done)
+	assert.Equal(t,
+		"HTTP/1.1 304 Not Modified\r\netag: \"abc\"\r\nlast-modified: Wed, 01 May 2024 12:00:00 GMT\r\n\r\n",
+		out)
+
+	// Test: If-None-Match hit on POST
+	done, out = checkPreconditions(t, "POST / HTTP/1.1\r\nIf-None-Match: *\r\n\r\n", validators())
+	assert.True(t, done)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 412 Precondition Failed\r\n"))
+
+	// Test: If-None-Match takes precedence over If-Modified-Since
+	done, _ = checkPreconditions(t,
+		"GET / HTTP/1.1\r\nIf-None-Match: \"xyz\"\r\nIf-Modified-Since: Wed, 01 May 2024 12:00:00 GMT\r\n\r\n",
+		validators())
+	assert.False(t, done)
+
+	// Test: If-Modified-Since not modified
+	done, out = checkPreconditions(t, "GET / HTTP/1.1\r\nIf-Modified-Since: Thu, 02 May 2024 00:00:00 GMT\r\n\r\n", validators())
+	assert.True(t, done)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 304 Not Modified\r\n"))
+
+	// Test: If-Modified-Since modified
+	done, _ = checkPreconditions(t, "GET / HTTP/1.1\r\nIf-Modified-Since: Tue, 30 Apr 2024 00:00:00 GMT\r\n\r\n", validators())
+	assert.False(t, done)
+
+	// Test: If-Match uses strong comparison
+	done, _ = checkPreconditions(t, "PUT / HTTP/1.1\r\nIf-Match: \"abc\"\r\n\r\n", validators())
+	assert.False(t, done)
+	done, out = checkPreconditions(t, "PUT / HTTP/1.1\r\nIf-Match: W/\"abc\"\r\n\r\n", validators())
+	assert.True(t, done)
+	assert.Equal(t, "HTTP/1.1 412 Precondition Failed\r\ncontent-length: 0\r\n\r\n", out)
+
+	// Test: If-Match takes precedence over If-Unmodified-Since
+	done, _ = checkPreconditions(t,
+		"PUT / HTTP/1.1\r\nIf-Match: \"abc\"\r\nIf-Unmodified-Since: Tue, 30 Apr 2024 00:00:00 GMT\r\n\r\n",
+		validators())
+	assert.False(t, done)
+
+	// Test: If-Unmodified-Since failed
+	done, out = checkPreconditions(t, "PUT / HTTP/1.1\r\nIf-Unmodified-Since: Tue, 30 Apr 2024 00:00:00 GMT\r\n\r\n", validators())
+	assert.True(t, done)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 412 Precondition Failed\r\n"))
+
+	// Test: Invalid dates are ignored
+	done, _ = checkPreconditions(t, "GET / HTTP/1.1\r\nIf-Modified-Since: yesterday\r\n\r\n", validators())
+	assert.False(t, done)
+}
+
+func TestETags(t *testing.T) {
+	assert.Equal(t, StrongETag([]byte("a")), StrongETag([]byte("a")))
+	assert.NotEqual(t, StrongETag([]byte("a")), StrongETag([]byte("b")))
+	assert.False(t, strings.HasPrefix(StrongETag([]byte("a")), "W/"))
+	assert.Equal(t, `W/"6632302c-a"`, WeakETag(10, time.Unix(0x6632302c, 0)))
+	assert.Equal(t, []string{`"a,b"`, `W/"c"`}, splitETags(` "a,b" , W/"c"`))
+}
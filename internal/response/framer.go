@@ -0,0 +1,24 @@
+package response
+
+import "http/internal/headers"
+
+// A Framer puts a response on the wire for protocols with their own message
+// framing, such as HTTP/2, in place of HTTP/1.1 text.
+type Framer interface {
+	// WriteHeaders sends an informational or final header section.
+	WriteHeaders(statusCode StatusCode, h headers.Headers, setCookies []string) error
+	// WriteData sends body bytes.
+	WriteData(p []byte) (int, error)
+	// WriteTrailers sends the trailer section, possibly empty, and ends the
+	// response.
+	WriteTrailers(h headers.Headers) error
+}
+
+// NewFramedWriter returns a Writer sending its response through f. Chunked
+// bodies reach f as plain data; the chunk framing is left out.
+func NewFramedWriter(f Framer) *Writer {
+	return &Writer{
+		framer:      f,
+		writerState: writeStateStatusLine,
+	}
+}
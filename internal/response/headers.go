@@ -1,24 +1,14 @@
 package response
 
 import (
-	"fmt"
-
 	"http/internal/headers"
 )
 
 func GetDefaultHeaders(contentLen int) headers.Headers {
 	h := headers.NewHeaders()
-	h.Set("Content-Length", fmt.Sprintf("%d", contentLen))
+	h.SetContentLength(int64(contentLen))
 	h.Set("Connection", "close")
 	h.Set("Content-Type", "text/plain")
 
 	return h
 }
-
-func (w *Writer) WriteTrailers(h headers.Headers) error {
-	err := w.WriteHeaders(h)
-	if err != nil {
-		return err
-	}
-	return nil
-}
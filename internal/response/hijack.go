@@ -0,0 +1,44 @@
+package response
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	ErrNotHijackable = errors.New("connection cannot be hijacked")
+	ErrHijacked      = errors.New("connection has been hijacked")
+)
+
+// SetHijacker is called by the server to let handlers take over the
+// connection with Hijack.
+func (w *Writer) SetHijacker(fn func() (net.Conn, []byte, error)) {
+	w.hijack = fn
+}
+
+// Hijack hands the connection over to the caller, for protocols such as
+// WebSocket that take over the socket after the response headers. It also
+// returns the bytes the server read from the connection but did not parse,
+// which must be consumed before reading from the connection. The server
+// neither writes to nor closes a hijacked connection, and the Writer can no
+// longer be used.
+func (w *Writer) Hijack() (net.Conn, []byte, error) {
+	if w.hijack == nil {
+		return nil, nil, ErrNotHijackable
+	}
+	if w.hijacked {
+		return nil, nil, ErrHijacked
+	}
+	conn, buffered, err := w.hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.hijacked = true
+	w.writerState = writeStateDone
+	return conn, buffered, nil
+}
+
+// Hijacked reports whether Hijack has taken over the connection.
+func (w *Writer) Hijacked() bool {
+	return w.hijacked
+}
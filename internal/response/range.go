@@ -0,0 +1,261 @@
+package response
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"http/internal/headers"
+	"http/internal/request"
+)
+
+// maxRanges is the most ranges ServeContent answers with a multipart body.
+// Requests for more, after merging, get the whole content instead.
+const maxRanges = 32
+
+var (
+	ErrInvalidRange        = errors.New("invalid range")
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+)
+
+// ByteRange is a span of Length bytes starting at Start.
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+func (br ByteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.Start, br.Start+br.Length-1, size)
+}
+
+// ParseRange parses a Range header value against content of the given size.
+// Ranges past the end are clipped. It returns ErrInvalidRange for headers
+// that should be ignored and ErrRangeNotSatisfiable when no range overlaps
+// the content.
+func ParseRange(value string, size int64) ([]ByteRange, error) {
+	unit, spec, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(unit) != "bytes" {
+		return nil, ErrInvalidRange
+	}
+	var ranges []ByteRange
+	parsed := 0
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, ErrInvalidRange
+		}
+		parsed++
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+		var br ByteRange
+		if first == "" {
+			// suffix range: the last n bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, ErrInvalidRange
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			n = min(n, size)
+			br = ByteRange{Start: size - n, Length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, ErrInvalidRange
+			}
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, ErrInvalidRange
+				}
+				end = min(end, size-1)
+			}
+			if start >= size {
+				continue
+			}
+			br = ByteRange{Start: start, Length: end - start + 1}
+		}
+		ranges = append(ranges, br)
+	}
+	if parsed == 0 {
+		return nil, ErrInvalidRange
+	}
+	if len(ranges) == 0 {
+		return nil, ErrRangeNotSatisfiable
+	}
+	return ranges, nil
+}
+
+// ServeContent writes content as the response to r, answering conditional
+// requests with 304 or 412 and Range requests with 206 Partial Content. h
+// holds the headers of the full representation; its ETag and Last-Modified
+// are the validators for the conditional and If-Range headers.
+func ServeContent(w *Writer, r *request.Request, h headers.Headers, content io.ReadSeeker) error {
+	if done, err := CheckPreconditions(w, r, h); done || err != nil {
+		return err
+	}
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	h.Override("Accept-Ranges", "bytes")
+
+	var ranges []ByteRange
+	rangeHeader := r.Headers.Get("Range")
+	if rangeHeader != "" && r.RequestLine.Method == "GET" && ifRangeMatches(r, h) {
+		ranges, err = ParseRange(rangeHeader, size)
+		if errors.Is(err, ErrRangeNotSatisfiable) {
+			h.Override("Content-Range", fmt.Sprintf("bytes */%d", size))
+			h.SetContentLength(0)
+			if err := w.WriteStatusLine(StatusRangeNotSatisfiable); err != nil {
+				return err
+			}
+			return w.WriteHeaders(h)
+		}
+		ranges = coalesceRanges(ranges)
+		if err != nil || len(ranges) > maxRanges {
+			ranges = nil
+		}
+	}
+
+	switch len(ranges) {
+	case 0:
+		h.SetContentLength(size)
+		if err := w.WriteStatusLine(StatusOk); err != nil {
+			return err
+		}
+		if err := w.WriteHeaders(h); err != nil {
+			return err
+		}
+		_, err := io.CopyN(w, content, size)
+		return err
+	case 1:
+		br := ranges[0]
+		h.Override("Content-Range", br.contentRange(size))
+		h.SetContentLength(br.Length)
+		if err := w.WriteStatusLine(StatusPartialContent); err != nil {
+			return err
+		}
+		if err := w.WriteHeaders(h); err != nil {
+			return err
+		}
+		if _, err := content.Seek(br.Start, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := io.CopyN(w, content, br.Length)
+		return err
+	default:
+		return serveMultipartRanges(w, h, content, size, ranges)
+	}
+}
+
+func serveMultipartRanges(w *Writer, h headers.Headers, content io.ReadSeeker, size int64, ranges []ByteRange) error {
+	boundary, err := randomBoundary()
+	if err != nil {
+		return err
+	}
+	contentType := h.Get("Content-Type")
+	partHeader := func(br ByteRange) string {
+		var b strings.Builder
+		fmt.Fprintf(&b, "\r\n--%s\r\n", boundary)
+		if contentType != "" {
+			fmt.Fprintf(&b, "Content-Type: %s\r\n", contentType)
+		}
+		fmt.Fprintf(&b, "Content-Range: %s\r\n\r\n", br.contentRange(size))
+		return b.String()
+	}
+	closing := fmt.Sprintf("\r\n--%s--\r\n", boundary)
+
+	length := int64(len(closing))
+	for _, br := range ranges {
+		length += int64(len(partHeader(br))) + br.Length
+	}
+	h.Override("Content-Type", "multipart/byteranges; boundary="+boundary)
+	h.SetContentLength(length)
+	if err := w.WriteStatusLine(StatusPartialContent); err != nil {
+		return err
+	}
+	if err := w.WriteHeaders(h); err != nil {
+		return err
+	}
+	for _, br := range ranges {
+		if _, err := w.WriteBody([]byte(partHeader(br))); err != nil {
+			return err
+		}
+		if _, err := content.Seek(br.Start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, content, br.Length); err != nil {
+			return err
+		}
+	}
+	_, err = w.WriteBody([]byte(closing))
+	return err
+}
+
+// ifRangeMatches reports whether a Range header should be honoured given
+// the request's If-Range validator. A missing If-Range always matches.
+func ifRangeMatches(r *request.Request, h headers.Headers) bool {
+	ifRange := strings.TrimSpace(r.Headers.Get("If-Range"))
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		etag := h.Get("ETag")
+		return etag != "" && !strings.HasPrefix(etag, "W/") && etag == ifRange
+	}
+	lastModified, ok := parseHTTPDate(h.Get("Last-Modified"))
+	if !ok {
+		return false
+	}
+	since, ok := parseHTTPDate(ifRange)
+	if !ok {
+		return false
+	}
+	return lastModified.Equal(since)
+}
+
+// coalesceRanges sorts ranges and merges those that overlap or are
+// adjacent, so that no byte is sent twice and tiny ranges cannot multiply
+// the parts of a multipart response.
+func coalesceRanges(ranges []ByteRange) []ByteRange {
+	if len(ranges) < 2 {
+		return ranges
+	}
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b ByteRange) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	merged := sorted[:1]
+	for _, br := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if br.Start <= last.Start+last.Length {
+			last.Length = max(last.Length, br.Start+br.Length-last.Start)
+			continue
+		}
+		merged = append(merged, br)
+	}
+	return merged
+}
+
+func randomBoundary() (string, error) {
+	buf := make([]byte, 15)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,143 @@
+package response
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/headers"
+	"http/internal/request"
+)
+
+func TestParseRange(t *testing.T) {
+	// Test: Single range
+	ranges, err := ParseRange("bytes=0-4", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []ByteRange{{Start: 0, Length: 5}}, ranges)
+
+	// Test: Open ended and clipped ranges
+	ranges, err = ParseRange("bytes=7-, 8-100", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []ByteRange{{Start: 7, Length: 3}, {Start: 8, Length: 2}}, ranges)
+
+	// Test: Suffix range
+	ranges, err = ParseRange("bytes=-3", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []ByteRange{{Start: 7, Length: 3}}, ranges)
+	ranges, err = ParseRange("bytes=-30", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []ByteRange{{Start: 0, Length: 10}}, ranges)
+
+	// Test: Unsatisfiable ranges
+	_, err = ParseRange("bytes=10-20", 10)
+	require.ErrorIs(t, err, ErrRangeNotSatisfiable)
+	_, err = ParseRange("bytes=-0", 10)
+	require.ErrorIs(t, err, ErrRangeNotSatisfiable)
+
+	// Test: Invalid ranges
+	for _, value := range []string{"bytes=", "bytes=5-1", "bytes=a-b", "items=0-1", "bytes=-"} {
+		_, err = ParseRange(value, 10)
+		require.ErrorIs(t, err, ErrInvalidRange, value)
+	}
+}
+
+func serveContent(t *testing.T, req string, h headers.Headers, content string) string {
+	t.Helper()
+	r, err := request.RequestFromReader(strings.NewReader(req))
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	w.SetRequestMethod(r.RequestLine.Method)
+	require.NoError(t, ServeContent(w, r, h, strings.NewReader(content)))
+	return buf.String()
+}
+
+func TestServeContent(t *testing.T) {
+	content := "0123456789"
+
+	// Test: No Range header
+	out := serveContent(t, "GET / HTTP/1.1\r\n\r\n", headers.NewHeaders(), content)
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\naccept-ranges: bytes\r\ncontent-length: 10\r\n\r\n0123456789",
+		out)
+
+	// Test: Single range
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=2-5\r\n\r\n", headers.NewHeaders(), content)
+	assert.Equal(t,
+		"HTTP/1.1 206 Partial Content\r\n"+
+			"accept-ranges: bytes\r\ncontent-length: 4\r\ncontent-range: bytes 2-5/10\r\n\r\n2345",
+		out)
+
+	// Test: Unsatisfiable range
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=20-\r\n\r\n", headers.NewHeaders(), content)
+	assert.Equal(t,
+		"HTTP/1.1 416 Range Not Satisfiable\r\n"+
+			"accept-ranges: bytes\r\ncontent-length: 0\r\ncontent-range: bytes */10\r\n\r\n",
+		out)
+
+	// Test: Multiple ranges
+	h := headers.NewHeaders()
+	h.Set("Content-Type", "text/plain")
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-1,-2\r\n\r\n", h, content)
+	head, body, ok := strings.Cut(out, "\r\n\r\n")
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 206 Partial Content\r\n"))
+	_, boundary, ok := strings.Cut(head, "boundary=")
+	require.True(t, ok)
+	assert.Equal(t,
+		"\r\n--"+boundary+"\r\nContent-Type: text/plain\r\nContent-Range: bytes 0-1/10\r\n\r\n01"+
+			"\r\n--"+boundary+"\r\nContent-Type: text/plain\r\nContent-Range: bytes 8-9/10\r\n\r\n89"+
+			"\r\n--"+boundary+"--\r\n",
+		body)
+	assert.Contains(t, head, fmt.Sprintf("content-length: %d\r\n", len(body)))
+
+	// Test: Overlapping and adjacent ranges are merged
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=4-5,0-1,1-3,-2\r\n\r\n", headers.NewHeaders(), content)
+	head, body, ok = strings.Cut(out, "\r\n\r\n")
+	require.True(t, ok)
+	_, boundary, ok = strings.Cut(head, "boundary=")
+	require.True(t, ok)
+	assert.Equal(t,
+		"\r\n--"+boundary+"\r\nContent-Range: bytes 0-5/10\r\n\r\n012345"+
+			"\r\n--"+boundary+"\r\nContent-Range: bytes 8-9/10\r\n\r\n89"+
+			"\r\n--"+boundary+"--\r\n",
+		body)
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-0,1-1,2-2,3-3\r\n\r\n", headers.NewHeaders(), content)
+	assert.Equal(t,
+		"HTTP/1.1 206 Partial Content\r\n"+
+			"accept-ranges: bytes\r\ncontent-length: 4\r\ncontent-range: bytes 0-3/10\r\n\r\n0123",
+		out)
+
+	// Test: Too many ranges get the whole content
+	var many []string
+	for i := 0; i < 200; i += 2 {
+		many = append(many, fmt.Sprintf("%d-%d", i, i))
+	}
+	long := strings.Repeat("x", 200)
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes="+strings.Join(many, ",")+"\r\n\r\n", headers.NewHeaders(), long)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\naccept-ranges: bytes\r\ncontent-length: 200\r\n\r\n"+long, out)
+
+	// Test: If-Range with matching and stale ETag
+	h = headers.NewHeaders()
+	h.Set("ETag", `"v1"`)
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-0\r\nIf-Range: \"v1\"\r\n\r\n", h, content)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 206 Partial Content\r\n"))
+	h = headers.NewHeaders()
+	h.Set("ETag", `"v2"`)
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-0\r\nIf-Range: \"v1\"\r\n\r\n", h, content)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+
+	// Test: If-Range with a date
+	h = headers.NewHeaders()
+	h.Set("Last-Modified", "Sun, 06 Nov 1994 08:49:37 GMT")
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-0\r\nIf-Range: Sun, 06 Nov 1994 08:49:37 GMT\r\n\r\n", h, content)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 206 Partial Content\r\n"))
+
+	// Test: Range ignored for HEAD
+	out = serveContent(t, "HEAD / HTTP/1.1\r\nRange: bytes=0-0\r\n\r\n", headers.NewHeaders(), content)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\naccept-ranges: bytes\r\ncontent-length: 10\r\n\r\n", out)
+}
@@ -0,0 +1,189 @@
+package response
+
+import (
+	"bytes"
+
+	"http/internal/headers"
+)
+
+const DefaultBufferSize = 4096
+
+// ResponseWriter is a higher level writer on top of Writer. Headers can be
+// changed until the first write, small bodies are buffered so Content-Length
+// is set automatically, and bodies larger than the buffer are sent chunked.
+// Close must be called once the handler is done.
+type ResponseWriter struct {
+	w          *Writer
+	header     headers.Headers
+	statusCode StatusCode
+	bufferSize int
+	buf        bytes.Buffer
+	// discarded counts the body bytes dropped for HEAD requests, which
+	// still make up the Content-Length
+	discarded int64
+	committed bool
+	chunked   bool
+	closed    bool
+}
+
+func NewResponseWriter(w *Writer) *ResponseWriter {
+	return NewResponseWriterSize(w, DefaultBufferSize)
+}
+
+func NewResponseWriterSize(w *Writer, bufferSize int) *ResponseWriter {
+	h := headers.NewHeaders()
+	h.Set("Connection", "close")
+	return &ResponseWriter{
+		w:          w,
+		header:     h,
+		bufferSize: bufferSize,
+	}
+}
+
+// Header returns the headers that will be sent with the response. Changes
+// after the headers have been written have no effect.
+func (rw *ResponseWriter) Header() headers.Headers {
+	return rw.header
+}
+
+// SetCookie adds a Set-Cookie field line to the response. It must be called
+// before the first write.
+func (rw *ResponseWriter) SetCookie(c *headers.Cookie) error {
+	if rw.committed {
+		return ErrOutOfOrder
+	}
+	return rw.w.SetCookie(c)
+}
+
+// WriteHeader sets the response status code. Only the first call has an
+// effect; Write calls it with StatusOk when it has not been called.
+func (rw *ResponseWriter) WriteHeader(statusCode StatusCode) {
+	if rw.statusCode != 0 {
+		return
+	}
+	rw.statusCode = statusCode
+}
+
+func (rw *ResponseWriter) Write(p []byte) (int, error) {
+	if rw.closed {
+		return 0, ErrOutOfOrder
+	}
+	rw.WriteHeader(StatusOk)
+	if rw.committed {
+		return rw.writeBody(p)
+	}
+	if rw.header.Get("Content-Length") != "" {
+		if err := rw.commit(); err != nil {
+			return 0, err
+		}
+		return rw.writeBody(p)
+	}
+	if !rw.w.BodyAllowed() {
+		rw.discarded += int64(len(p))
+		return len(p), nil
+	}
+	if rw.buf.Len()+len(p) <= rw.bufferSize {
+		return rw.buf.Write(p)
+	}
+	rw.chunked = true
+	if err := rw.commit(); err != nil {
+		return 0, err
+	}
+	if err := rw.flushBuffer(); err != nil {
+		return 0, err
+	}
+	return rw.writeBody(p)
+}
+
+// Flush sends the headers and any buffered body. The response switches to
+// chunked encoding if the content length is not yet known.
+func (rw *ResponseWriter) Flush() error {
+	if rw.closed {
+		return ErrOutOfOrder
+	}
+	rw.WriteHeader(StatusOk)
+	if !rw.committed {
+		if rw.header.Get("Content-Length") == "" {
+			rw.chunked = true
+		}
+		if err := rw.commit(); err != nil {
+			return err
+		}
+	}
+	return rw.flushBuffer()
+}
+
+// Close finishes the response, writing the headers and buffered body if
+// nothing has been sent yet, or the last chunk for chunked responses.
+func (rw *ResponseWriter) Close() error {
+	if rw.closed {
+		return nil
+	}
+	rw.WriteHeader(StatusOk)
+	if !rw.committed {
+		if rw.header.Get("Content-Length") == "" && hasContentLength(rw.statusCode) {
+			rw.header.SetContentLength(int64(rw.buf.Len()) + rw.discarded)
+		}
+		if err := rw.commit(); err != nil {
+			return err
+		}
+		if err := rw.flushBuffer(); err != nil {
+			return err
+		}
+	}
+	rw.closed = true
+	if rw.chunked {
+		_, err := rw.w.WriteChunkedBodyDone(headers.NewHeaders())
+		return err
+	}
+	return nil
+}
+
+// hasContentLength reports whether a response with statusCode carries the
+// length of its body. 1xx and 204 responses must not send Content-Length, and
+// for 304 it would describe the selected representation rather than the
+// empty body (RFC 9110 section 8.6).
+func hasContentLength(statusCode StatusCode) bool {
+	return statusCode >= 200 && statusCode != StatusNoContent && statusCode != StatusNotModified
+}
+
+func (rw *ResponseWriter) commit() error {
+	if rw.header.Get("Content-Type") == "" {
+		rw.header.Set("Content-Type", "text/plain")
+	}
+	if rw.chunked {
+		rw.header.Remove("Content-Length")
+		rw.header.Override("Transfer-Encoding", "chunked")
+	}
+	if err := rw.w.WriteStatusLine(rw.statusCode); err != nil {
+		return err
+	}
+	if err := rw.w.WriteHeaders(rw.header); err != nil {
+		return err
+	}
+	rw.committed = true
+	return nil
+}
+
+func (rw *ResponseWriter) flushBuffer() error {
+	if rw.buf.Len() == 0 {
+		return nil
+	}
+	_, err := rw.writeBody(rw.buf.Bytes())
+	rw.buf.Reset()
+	return err
+}
+
+func (rw *ResponseWriter) writeBody(p []byte) (int, error) {
+	if !rw.chunked {
+		return rw.w.WriteBody(p)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	_, err := rw.w.WriteChunkedBody(p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
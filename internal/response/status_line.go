@@ -1,36 +1,151 @@
 package response
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"http/internal/headers"
+)
+
+var ErrNotInformational = errors.New("status code is not informational")
 
 type StatusCode int
 
 const (
-	_                                    = iota
-	StatusOk                  StatusCode = 200
-	StatusBadRequest                     = 400
-	StatusInternalServerError            = 500
+	_                                      = iota
+	StatusContinue              StatusCode = 100
+	StatusSwitchingProtocols    StatusCode = 101
+	StatusProcessing            StatusCode = 102
+	StatusEarlyHints            StatusCode = 103
+	StatusOk                    StatusCode = 200
+	StatusNoContent             StatusCode = 204
+	StatusPartialContent        StatusCode = 206
+	StatusMovedPermanently      StatusCode = 301
+	StatusNotModified           StatusCode = 304
+	StatusBadRequest            StatusCode = 400
+	StatusUnauthorized          StatusCode = 401
+	StatusForbidden             StatusCode = 403
+	StatusNotFound              StatusCode = 404
+	StatusMethodNotAllowed      StatusCode = 405
+	StatusNotAcceptable         StatusCode = 406
+	StatusPreconditionFailed    StatusCode = 412
+	StatusRequestEntityTooLarge StatusCode = 413
+	StatusUnsupportedMediaType  StatusCode = 415
+	StatusRangeNotSatisfiable   StatusCode = 416
+	StatusExpectationFailed     StatusCode = 417
+	StatusUpgradeRequired       StatusCode = 426
+	StatusInternalServerError   StatusCode = 500
 )
 
-func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
-	if w.writerState != writeStateStatusLine {
-		return ErrOutOfOrder
-	}
-	phrase := ""
+// StatusText returns the reason phrase for a status code.
+func StatusText(statusCode StatusCode) string {
 	switch statusCode {
+	case StatusContinue:
+		return "Continue"
+	case StatusSwitchingProtocols:
+		return "Switching Protocols"
+	case StatusProcessing:
+		return "Processing"
+	case StatusEarlyHints:
+		return "Early Hints"
 	case StatusOk:
-		phrase = "OK"
+		return "OK"
+	case StatusNoContent:
+		return "No Content"
+	case StatusPartialContent:
+		return "Partial Content"
+	case StatusMovedPermanently:
+		return "Moved Permanently"
+	case StatusNotModified:
+		return "Not Modified"
 	case StatusBadRequest:
-		phrase = "Bad Request"
+		return "Bad Request"
+	case StatusUnauthorized:
+		return "Unauthorized"
+	case StatusForbidden:
+		return "Forbidden"
+	case StatusNotFound:
+		return "Not Found"
+	case StatusMethodNotAllowed:
+		return "Method Not Allowed"
+	case StatusNotAcceptable:
+		return "Not Acceptable"
+	case StatusPreconditionFailed:
+		return "Precondition Failed"
+	case StatusRequestEntityTooLarge:
+		return "Content Too Large"
+	case StatusUnsupportedMediaType:
+		return "Unsupported Media Type"
+	case StatusRangeNotSatisfiable:
+		return "Range Not Satisfiable"
+	case StatusExpectationFailed:
+		return "Expectation Failed"
+	case StatusUpgradeRequired:
+		return "Upgrade Required"
 	case StatusInternalServerError:
-		phrase = "Internal Server Error"
+		return "Internal Server Error"
 	default:
-		phrase = ""
+		return ""
 	}
-	response := fmt.Sprintf("HTTP/1.1 %d %s\r\n", statusCode, phrase)
+}
+
+func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
+	if w.writerState != writeStateStatusLine {
+		return ErrOutOfOrder
+	}
+	for _, hook := range w.statusHooks {
+		if err := hook(statusCode); err != nil {
+			return err
+		}
+	}
+	if w.framer == nil {
+		response := fmt.Sprintf("HTTP/1.1 %d %s\r\n", statusCode, StatusText(statusCode))
+		_, err := w.writer.Write([]byte(response))
+		if err != nil {
+			return err
+		}
+	}
+	w.statusCode = statusCode
+	if statusCode == StatusSwitchingProtocols || statusCode == StatusNoContent ||
+		statusCode == StatusNotModified {
+		w.noBody = true
+	}
+	w.writerState = writeStateHeader
+	return nil
+}
+
+// StatusWritten reports whether the final status line has been written, or
+// the connection hijacked, so that it is too late to choose a status code.
+func (w *Writer) StatusWritten() bool {
+	return w.writerState != writeStateStatusLine
+}
+
+// WriteInformational sends an interim 1xx response with the given headers.
+// It may be called any number of times before the final status line and
+// does not advance the writer's state.
+func (w *Writer) WriteInformational(statusCode StatusCode, h headers.Headers) error {
+	if w.writerState != writeStateStatusLine {
+		return ErrOutOfOrder
+	}
+	if statusCode < 100 || statusCode > 199 || statusCode == 101 {
+		return ErrNotInformational
+	}
+	if w.framer != nil {
+		return w.framer.WriteHeaders(statusCode, h, nil)
+	}
+	response := fmt.Sprintf("HTTP/1.1 %d %s\r\n", statusCode, StatusText(statusCode))
 	_, err := w.writer.Write([]byte(response))
 	if err != nil {
 		return err
 	}
-	w.writerState = writeStateHeader
-	return nil
+	return w.writeFieldLines(h, nil)
+}
+
+// WriteContinue sends an interim "100 Continue" response. It is a no-op once
+// the final status line has been written.
+func (w *Writer) WriteContinue() error {
+	if w.writerState != writeStateStatusLine {
+		return nil
+	}
+	return w.WriteInformational(StatusContinue, nil)
 }
@@ -0,0 +1,18 @@
+package response
+
+import "http/internal/headers"
+
+// SwitchProtocols writes a 101 Switching Protocols response announcing
+// protocol, along with any extra headers in h. The connection speaks protocol
+// right after it, so the caller normally hijacks the connection next.
+func (w *Writer) SwitchProtocols(protocol string, h headers.Headers) error {
+	if h == nil {
+		h = headers.NewHeaders()
+	}
+	h.Override("Upgrade", protocol)
+	h.Override("Connection", "Upgrade")
+	if err := w.WriteStatusLine(StatusSwitchingProtocols); err != nil {
+		return err
+	}
+	return w.WriteHeaders(h)
+}
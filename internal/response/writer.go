@@ -4,12 +4,45 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"sort"
 	"strconv"
+	"strings"
 
 	"http/internal/headers"
 )
 
-var ErrOutOfOrder = errors.New("out of order write")
+var (
+	ErrOutOfOrder        = errors.New("out of order write")
+	ErrNotChunked        = errors.New("trailers require a chunked response")
+	ErrUndeclaredTrailer = errors.New("trailer field not declared in Trailer header")
+	ErrForbiddenTrailer  = errors.New("field not allowed in trailers")
+)
+
+// forbiddenTrailers are fields needed to frame, route or authenticate a
+// message, which must not be sent as trailers.
+var forbiddenTrailers = map[string]bool{
+	"authorization":       true,
+	"cache-control":       true,
+	"connection":          true,
+	"content-encoding":    true,
+	"content-length":      true,
+	"content-range":       true,
+	"content-type":        true,
+	"expect":              true,
+	"host":                true,
+	"keep-alive":          true,
+	"max-forwards":        true,
+	"pragma":              true,
+	"proxy-authenticate":  true,
+	"proxy-authorization": true,
+	"range":               true,
+	"set-cookie":          true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+	"www-authenticate":    true,
+}
 
 type WriterState int
 
@@ -17,12 +50,60 @@ const (
 	writeStateStatusLine WriterState = iota
 	writeStateHeader
 	writeStateBody
+	writeStateTrailers
 	writeStateDone
 )
 
 type Writer struct {
 	writer      io.Writer
+	framer      Framer
 	writerState WriterState
+	chunked     bool
+	trailers    map[string]bool
+	noBody      bool
+	statusCode  StatusCode
+	encodeBody  BodyEncoderFunc
+	encoder     io.WriteCloser
+	autoChunked bool
+	cookies     []string
+	statusHooks []StatusLineHookFunc
+	headerHooks []HeadersHookFunc
+	finishHooks []func() error
+	hijack      func() (net.Conn, []byte, error)
+	hijacked    bool
+}
+
+// A StatusLineHookFunc is called when the final status line is about to be
+// written. An error aborts WriteStatusLine before anything is sent, so that
+// a different response can still be written.
+type StatusLineHookFunc func(statusCode StatusCode) error
+
+// OnWriteStatusLine registers fn to run before the final status line is
+// written. Hooks run in registration order.
+func (w *Writer) OnWriteStatusLine(fn StatusLineHookFunc) {
+	w.statusHooks = append(w.statusHooks, fn)
+}
+
+// A HeadersHookFunc is called when the headers are about to be written. It
+// may modify the headers or add cookies. An error aborts WriteHeaders.
+type HeadersHookFunc func(statusCode StatusCode, h headers.Headers) error
+
+// OnWriteHeaders registers fn to run before the headers are written. Hooks
+// run in registration order, before the body encoder.
+func (w *Writer) OnWriteHeaders(fn HeadersHookFunc) {
+	w.headerHooks = append(w.headerHooks, fn)
+}
+
+// A BodyEncoderFunc is called when the headers are about to be written. It
+// may modify the headers and returns a writer wrapping dst through which the
+// body is encoded, or nil to send the body unchanged. Encoded bodies are
+// always sent chunked since their length is not known up front.
+type BodyEncoderFunc func(statusCode StatusCode, h headers.Headers, dst io.Writer) io.WriteCloser
+
+// SetBodyEncoder installs fn to encode the body of the response, for example
+// to compress it. It must be called before the headers are written.
+func (w *Writer) SetBodyEncoder(fn BodyEncoderFunc) {
+	w.encodeBody = fn
 }
 
 func NewWriter(w io.Writer) *Writer {
@@ -32,70 +113,293 @@ func NewWriter(w io.Writer) *Writer {
 	}
 }
 
-func (w *Writer) WriteHeaders(headers headers.Headers) error {
+// SetRequestMethod tells the writer which request it is answering. Bodies of
+// responses to HEAD requests are discarded.
+func (w *Writer) SetRequestMethod(method string) {
+	if method == "HEAD" {
+		w.noBody = true
+	}
+}
+
+// BodyAllowed reports whether body bytes are sent on the wire. It is false
+// for HEAD requests and for 204 and 304 responses, in which case writes to
+// the body succeed but are discarded.
+func (w *Writer) BodyAllowed() bool {
+	return !w.noBody
+}
+
+// SetCookie adds a Set-Cookie field line to the response. Each cookie is
+// sent on its own line, since Set-Cookie values cannot be combined with
+// commas. It must be called before the headers are written.
+func (w *Writer) SetCookie(c *headers.Cookie) error {
+	if w.writerState > writeStateHeader {
+		return ErrOutOfOrder
+	}
+	if err := c.Valid(); err != nil {
+		return err
+	}
+	w.cookies = append(w.cookies, c.String())
+	return nil
+}
+
+func (w *Writer) WriteHeaders(h headers.Headers) error {
 	if w.writerState != writeStateHeader {
 		return ErrOutOfOrder
 	}
-	for k, v := range headers {
-		fieldLine := fmt.Sprintf("%s: %s\r\n", k, v)
-		_, err := w.writer.Write([]byte(fieldLine))
-		if err != nil {
+	for _, hook := range w.headerHooks {
+		if err := hook(w.statusCode, h); err != nil {
 			return err
 		}
 	}
-	w.writer.Write([]byte("\r\n"))
+	trailers := map[string]bool{}
+	for _, name := range headers.SplitList(h.Get("Trailer")) {
+		name = strings.ToLower(name)
+		if forbiddenTrailers[name] {
+			return fmt.Errorf("%w: %s", ErrForbiddenTrailer, name)
+		}
+		trailers[name] = true
+	}
+	chunked := false
+	for _, coding := range headers.SplitList(h.Get("Transfer-Encoding")) {
+		chunked = strings.EqualFold(coding, "chunked")
+	}
+	var encoder io.WriteCloser
+	if w.encodeBody != nil {
+		encoder = w.encodeBody(w.statusCode, h, chunkSink{w})
+	}
+	if encoder != nil {
+		h.Remove("Content-Length")
+		if !chunked {
+			h.Set("Transfer-Encoding", "chunked")
+			chunked = true
+			w.autoChunked = true
+		}
+	}
+	var err error
+	if w.framer != nil {
+		err = w.framer.WriteHeaders(w.statusCode, h, w.cookies)
+	} else {
+		err = w.writeFieldLines(h, w.cookies)
+	}
+	if err != nil {
+		return err
+	}
+	w.trailers = trailers
+	w.chunked = chunked
+	w.encoder = encoder
 	w.writerState = writeStateBody
 	return nil
 }
 
+// chunkSink receives the output of a body encoder and frames it as chunks.
+type chunkSink struct {
+	w *Writer
+}
+
+func (cs chunkSink) Write(p []byte) (int, error) {
+	if _, err := cs.w.writeChunk(p, ""); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFieldLines writes the headers in sorted order and one set-cookie line
+// per cookie, followed by the empty line ending the header section.
+func (w *Writer) writeFieldLines(h headers.Headers, setCookies []string) error {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fieldLine := fmt.Sprintf("%s: %s\r\n", k, h[k])
+		_, err := w.writer.Write([]byte(fieldLine))
+		if err != nil {
+			return err
+		}
+	}
+	for _, cookie := range setCookies {
+		_, err := w.writer.Write([]byte("set-cookie: " + cookie + "\r\n"))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.writer.Write([]byte("\r\n"))
+	return err
+}
+
 func (w *Writer) WriteBody(p []byte) (int, error) {
 	if w.writerState != writeStateBody {
 		return 0, ErrOutOfOrder
 	}
-	n, err := w.writer.Write(p)
-	if err != nil {
-		return 0, err
+	if w.encoder != nil {
+		return w.encoder.Write(p)
 	}
-	return n, nil
+	if w.autoChunked {
+		return w.writeChunk(p, "")
+	}
+	return w.writeRaw(p)
+}
+
+// Write is WriteBody, making the Writer an io.Writer for the body.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.WriteBody(p)
 }
 
 func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
+	return w.writeChunkData(p, "")
+}
+
+// writeChunkData sends p as a chunk with the given extensions, passing it
+// through the body encoder if one is active.
+func (w *Writer) writeChunkData(p []byte, extensions string) (int, error) {
+	if w.writerState != writeStateBody {
+		return 0, ErrOutOfOrder
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.writeChunk(p, extensions)
+}
+
+func (w *Writer) writeChunk(p []byte, extensions string) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if w.framer != nil {
+		return w.writeRaw(p)
+	}
 	totalBytes := 0
-	dataLenLine := fmt.Sprintf("%s\r\n", strconv.FormatInt(int64(len(p)), 16))
-	n, err := w.WriteBody([]byte(dataLenLine))
+	dataLenLine := fmt.Sprintf("%s%s\r\n", strconv.FormatInt(int64(len(p)), 16), extensions)
+	n, err := w.writeRaw([]byte(dataLenLine))
 	if err != nil {
 		return 0, err
 	}
 	totalBytes += n
-	n, err = w.WriteBody(p)
+	n, err = w.writeRaw(p)
 	if err != nil {
 		return totalBytes, err
 	}
 	totalBytes += n
-	n, err = w.WriteBody([]byte("\r\n"))
+	n, err = w.writeRaw([]byte("\r\n"))
 	if err != nil {
 		return totalBytes, err
 	}
 	return totalBytes + n, nil
 }
 
-func (w *Writer) WriteChunkedBodyDone(h headers.Headers) (int, error) {
-	tN := 0
-	n, err := w.WriteBody([]byte("0\r\n"))
+func (w *Writer) writeRaw(p []byte) (int, error) {
+	if w.noBody {
+		return len(p), nil
+	}
+	if w.framer != nil {
+		return w.framer.WriteData(p)
+	}
+	n, err := w.writer.Write(p)
 	if err != nil {
 		return 0, err
 	}
-	tN += n
-	w.writerState = writeStateHeader
-	err = w.WriteHeaders(h)
-	if err != nil {
+	return n, nil
+}
+
+// WriteChunkedBodyDone writes the last chunk followed by the trailers.
+func (w *Writer) WriteChunkedBodyDone(h headers.Headers) (int, error) {
+	if w.writerState != writeStateBody {
+		return 0, ErrOutOfOrder
+	}
+	if !w.chunked {
+		return 0, ErrNotChunked
+	}
+	if err := w.checkTrailers(h); err != nil {
 		return 0, err
 	}
-	w.writerState = writeStateBody
-	n, err = w.WriteBody([]byte("\r\n"))
+	if w.encoder != nil {
+		encoder := w.encoder
+		w.encoder = nil
+		if err := encoder.Close(); err != nil {
+			return 0, err
+		}
+	}
+	n := 0
+	if w.framer == nil {
+		var err error
+		n, err = w.writeRaw([]byte("0\r\n"))
+		if err != nil {
+			return 0, err
+		}
+	}
+	w.writerState = writeStateTrailers
+	err := w.WriteTrailers(h)
+	if err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// OnFinish registers fn to run first thing in Finish, so that writers still
+// sending in the background can end their part of the response before the
+// writer ends the body.
+func (w *Writer) OnFinish(fn func() error) {
+	w.finishHooks = append(w.finishHooks, fn)
+}
+
+// Finish completes the response once the handler has returned. Chunked
+// bodies that were not ended by the handler, including bodies switched to
+// chunked coding by a body encoder, get their last chunk.
+func (w *Writer) Finish() error {
+	hooks := w.finishHooks
+	w.finishHooks = nil
+	for _, hook := range hooks {
+		if err := hook(); err != nil {
+			return err
+		}
+	}
+	if w.writerState != writeStateBody || !w.chunked {
+		return nil
+	}
+	_, err := w.WriteChunkedBodyDone(headers.NewHeaders())
+	return err
+}
+
+// WriteTrailers ends a chunked response with the given trailer fields. If the
+// last chunk has not been written yet it is written first. Every field must be
+// declared in the Trailer header and be allowed in trailers.
+func (w *Writer) WriteTrailers(h headers.Headers) error {
+	if w.writerState == writeStateBody {
+		_, err := w.WriteChunkedBodyDone(h)
+		return err
+	}
+	if w.writerState != writeStateTrailers {
+		return ErrOutOfOrder
+	}
+	if err := w.checkTrailers(h); err != nil {
+		return err
+	}
+	w.writerState = writeStateDone
+	if w.noBody {
+		return nil
+	}
+	if w.framer != nil {
+		return w.framer.WriteTrailers(h)
+	}
+	err := w.writeFieldLines(h, nil)
 	if err != nil {
-		return tN, nil
+		return err
+	}
+	return nil
+}
+
+func (w *Writer) checkTrailers(h headers.Headers) error {
+	if !w.chunked {
+		return ErrNotChunked
 	}
-	tN += n
-	return tN, nil
+	for k := range h {
+		if forbiddenTrailers[k] {
+			return fmt.Errorf("%w: %s", ErrForbiddenTrailer, k)
+		}
+		if !w.trailers[k] {
+			return fmt.Errorf("%w: %s", ErrUndeclaredTrailer, k)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,383 @@
+package response
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/headers"
+)
+
+func TestWriteInformational(t *testing.T) {
+	// Test: 100 Continue before final response
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	require.NoError(t, w.WriteInformational(StatusContinue, nil))
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	h := headers.NewHeaders()
+	h.Set("Content-Length", "2")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err := w.WriteBody([]byte("ok"))
+	require.NoError(t, err)
+	assert.Equal(t,
+		"HTTP/1.1 100 Continue\r\n\r\n"+
+			"HTTP/1.1 200 OK\r\ncontent-length: 2\r\n\r\nok",
+		buf.String())
+
+	// Test: 103 Early Hints with Link preloads
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	h = headers.NewHeaders()
+	h.Set("Link", "</style.css>; rel=preload; as=style")
+	h.Set("Link", "</script.js>; rel=preload; as=script")
+	require.NoError(t, w.WriteInformational(StatusEarlyHints, h))
+	assert.Equal(t,
+		"HTTP/1.1 103 Early Hints\r\n"+
+			"link: </style.css>; rel=preload; as=style, </script.js>; rel=preload; as=script\r\n\r\n",
+		buf.String())
+
+	// Test: Multiple interim responses keep the writer in status line state
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteInformational(StatusProcessing, nil))
+	require.NoError(t, w.WriteInformational(StatusProcessing, nil))
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	assert.Equal(t,
+		"HTTP/1.1 102 Processing\r\n\r\n"+
+			"HTTP/1.1 102 Processing\r\n\r\n"+
+			"HTTP/1.1 200 OK\r\n",
+		buf.String())
+
+	// Test: Interim response after final status line
+	require.ErrorIs(t, w.WriteInformational(StatusContinue, nil), ErrOutOfOrder)
+
+	// Test: Non informational status code
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.ErrorIs(t, w.WriteInformational(StatusOk, nil), ErrNotInformational)
+	require.ErrorIs(t, w.WriteInformational(101, nil), ErrNotInformational)
+	assert.Empty(t, buf.String())
+}
+
+func TestResponseWriter(t *testing.T) {
+	// Test: Small body gets Content-Length and implicit 200
+	buf := &bytes.Buffer{}
+	rw := NewResponseWriter(NewWriter(buf))
+	rw.Header().Set("Content-Type", "text/html")
+	_, err := rw.Write([]byte("<p>hi</p>"))
+	require.NoError(t, err)
+	assert.Empty(t, buf.String())
+	require.NoError(t, rw.Close())
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"connection: close\r\ncontent-length: 9\r\ncontent-type: text/html\r\n\r\n"+
+			"<p>hi</p>",
+		buf.String())
+
+	// Test: Explicit status code and empty body
+	buf = &bytes.Buffer{}
+	rw = NewResponseWriter(NewWriter(buf))
+	rw.WriteHeader(StatusBadRequest)
+	rw.WriteHeader(StatusOk)
+	require.NoError(t, rw.Close())
+	assert.Equal(t,
+		"HTTP/1.1 400 Bad Request\r\n"+
+			"connection: close\r\ncontent-length: 0\r\ncontent-type: text/plain\r\n\r\n",
+		buf.String())
+
+	// Test: No Content-Length on 204 and 304 responses
+	for _, code := range []StatusCode{StatusNoContent, StatusNotModified} {
+		buf = &bytes.Buffer{}
+		rw = NewResponseWriter(NewWriter(buf))
+		rw.WriteHeader(code)
+		require.NoError(t, rw.Close())
+		assert.Equal(t,
+			fmt.Sprintf("HTTP/1.1 %d %s\r\n", code, StatusText(code))+
+				"connection: close\r\ncontent-type: text/plain\r\n\r\n",
+			buf.String())
+	}
+
+	// Test: 304 keeps a Content-Length set by the handler
+	buf = &bytes.Buffer{}
+	rw = NewResponseWriter(NewWriter(buf))
+	rw.Header().Set("Content-Length", "42")
+	rw.WriteHeader(StatusNotModified)
+	require.NoError(t, rw.Close())
+	assert.Contains(t, buf.String(), "content-length: 42\r\n")
+
+	// Test: Body larger than the buffer switches to chunked
+	buf = &bytes.Buffer{}
+	rw = NewResponseWriterSize(NewWriter(buf), 4)
+	_, err = rw.Write([]byte("abc"))
+	require.NoError(t, err)
+	_, err = rw.Write([]byte("defgh"))
+	require.NoError(t, err)
+	require.NoError(t, rw.Close())
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"connection: close\r\ncontent-type: text/plain\r\ntransfer-encoding: chunked\r\n\r\n"+
+			"3\r\nabc\r\n5\r\ndefgh\r\n0\r\n\r\n",
+		buf.String())
+
+	// Test: Handler provided Content-Length streams directly
+	buf = &bytes.Buffer{}
+	rw = NewResponseWriterSize(NewWriter(buf), 4)
+	rw.Header().Set("Content-Length", "6")
+	_, err = rw.Write([]byte("abcdef"))
+	require.NoError(t, err)
+	require.NoError(t, rw.Close())
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"connection: close\r\ncontent-length: 6\r\ncontent-type: text/plain\r\n\r\n"+
+			"abcdef",
+		buf.String())
+
+	// Test: Write after close
+	_, err = rw.Write([]byte("x"))
+	require.ErrorIs(t, err, ErrOutOfOrder)
+}
+
+func TestChunkedWriter(t *testing.T) {
+	// Test: Writes split by chunk size with declared trailers
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	h := headers.NewHeaders()
+	h.Set("Content-Length", "10")
+	h.Set("Trailer", "X-Checksum")
+	cw, err := NewChunkedWriter(w, h)
+	require.NoError(t, err)
+	cw.SetChunkSize(4)
+	n, err := cw.Write([]byte("hello world"))
+	require.NoError(t, err)
+	assert.Equal(t, 11, n)
+	cw.Trailer().Set("X-Checksum", "abc")
+	require.NoError(t, cw.Close())
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"trailer: X-Checksum\r\ntransfer-encoding: chunked\r\n\r\n"+
+			"4\r\nhell\r\n4\r\no wo\r\n3\r\nrld\r\n0\r\nx-checksum: abc\r\n\r\n",
+		buf.String())
+
+	// Test: ReadFrom writes only the bytes read
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	cw, err = NewChunkedWriter(w, headers.NewHeaders())
+	require.NoError(t, err)
+	cw.SetChunkSize(1024)
+	total, err := cw.ReadFrom(bytes.NewReader([]byte("short")))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	require.NoError(t, cw.Close())
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"transfer-encoding: chunked\r\n\r\n"+
+			"5\r\nshort\r\n0\r\n\r\n",
+		buf.String())
+
+	// Test: Chunk extensions
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	cw, err = NewChunkedWriter(w, headers.NewHeaders())
+	require.NoError(t, err)
+	require.NoError(t, cw.SetExtension("sig", "a b"))
+	require.NoError(t, cw.SetExtension("final", ""))
+	require.ErrorIs(t, cw.SetExtension("bad name", "x"), ErrInvalidExtension)
+	_, err = cw.Write([]byte("ab"))
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "2;sig=\"a b\";final\r\nab\r\n")
+
+	// Test: Undeclared trailer
+	cw.Trailer().Set("X-Other", "1")
+	require.ErrorIs(t, cw.Close(), ErrUndeclaredTrailer)
+}
+
+func TestWriteTrailers(t *testing.T) {
+	// Test: Declared trailers after the last chunk
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	h := headers.NewHeaders()
+	h.Set("Transfer-Encoding", "chunked")
+	h.Set("Trailer", "X-Digest, X-Length")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err := w.WriteChunkedBody([]byte("abc"))
+	require.NoError(t, err)
+	trailers := headers.NewHeaders()
+	trailers.Set("X-Digest", "d")
+	trailers.Set("X-Length", "3")
+	require.NoError(t, w.WriteTrailers(trailers))
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"trailer: X-Digest, X-Length\r\ntransfer-encoding: chunked\r\n\r\n"+
+			"3\r\nabc\r\n0\r\nx-digest: d\r\nx-length: 3\r\n\r\n",
+		buf.String())
+	_, err = w.WriteBody([]byte("late"))
+	require.ErrorIs(t, err, ErrOutOfOrder)
+
+	// Test: Without trailers the last chunk is followed by a single empty
+	// line, so nothing is left over before the next response
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	h = headers.NewHeaders()
+	h.Set("Transfer-Encoding", "chunked")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err = w.WriteChunkedBody([]byte("abc"))
+	require.NoError(t, err)
+	_, err = w.WriteChunkedBodyDone(headers.NewHeaders())
+	require.NoError(t, err)
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"transfer-encoding: chunked\r\n\r\n"+
+			"3\r\nabc\r\n0\r\n\r\n",
+		buf.String())
+
+	// Test: Undeclared trailer
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	h = headers.NewHeaders()
+	h.Set("Transfer-Encoding", "chunked")
+	h.Set("Trailer", "X-Digest")
+	require.NoError(t, w.WriteHeaders(h))
+	trailers = headers.NewHeaders()
+	trailers.Set("X-Other", "1")
+	_, err = w.WriteChunkedBodyDone(trailers)
+	require.ErrorIs(t, err, ErrUndeclaredTrailer)
+
+	// Test: Forbidden trailer declared in headers
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	h = headers.NewHeaders()
+	h.Set("Transfer-Encoding", "chunked")
+	h.Set("Trailer", "Content-Length")
+	require.ErrorIs(t, w.WriteHeaders(h), ErrForbiddenTrailer)
+
+	// Test: Trailers on a non chunked response
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	h = GetDefaultHeaders(0)
+	h.Set("Trailer", "X-Digest")
+	require.NoError(t, w.WriteHeaders(h))
+	trailers = headers.NewHeaders()
+	trailers.Set("X-Digest", "d")
+	require.ErrorIs(t, w.WriteTrailers(trailers), ErrNotChunked)
+}
+
+func TestBodySuppression(t *testing.T) {
+	// Test: HEAD keeps Content-Length but drops the body
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	w.SetRequestMethod("HEAD")
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	require.NoError(t, w.WriteHeaders(headers.Headers{"content-length": "5"}))
+	n, err := w.WriteBody([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.False(t, w.BodyAllowed())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\ncontent-length: 5\r\n\r\n", buf.String())
+
+	// Test: HEAD through ResponseWriter
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetRequestMethod("HEAD")
+	rw := NewResponseWriter(w)
+	_, err = rw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, rw.Close())
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"connection: close\r\ncontent-length: 5\r\ncontent-type: text/plain\r\n\r\n",
+		buf.String())
+
+	// Test: HEAD bodies larger than the buffer still get their length
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetRequestMethod("HEAD")
+	rw = NewResponseWriterSize(w, 16)
+	_, err = rw.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	_, err = rw.Write(bytes.Repeat([]byte("x"), 100))
+	require.NoError(t, err)
+	require.NoError(t, rw.Close())
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"connection: close\r\ncontent-length: 110\r\ncontent-type: text/plain\r\n\r\n",
+		buf.String())
+
+	// Test: HEAD with a chunked body and trailers
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetRequestMethod("HEAD")
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	cw, err := NewChunkedWriter(w, headers.Headers{"trailer": "X-Sum"})
+	require.NoError(t, err)
+	_, err = cw.Write([]byte("hello"))
+	require.NoError(t, err)
+	cw.Trailer().Set("X-Sum", "1")
+	require.NoError(t, cw.Close())
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\ntrailer: X-Sum\r\ntransfer-encoding: chunked\r\n\r\n",
+		buf.String())
+
+	// Test: 304 Not Modified for GET
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetRequestMethod("GET")
+	require.NoError(t, w.WriteStatusLine(StatusNotModified))
+	require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+	_, err = w.WriteBody([]byte("cached"))
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 304 Not Modified\r\n\r\n", buf.String())
+
+	// Test: GET writes the body
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetRequestMethod("GET")
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+	_, err = w.WriteBody([]byte("hello"))
+	require.NoError(t, err)
+	assert.True(t, w.BodyAllowed())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n\r\nhello", buf.String())
+}
+
+func TestSetCookie(t *testing.T) {
+	// Test: Each cookie on its own line after the sorted headers
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	require.NoError(t, w.SetCookie(&headers.Cookie{Name: "a", Value: "1", Path: "/", HttpOnly: true}))
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	require.NoError(t, w.SetCookie(&headers.Cookie{Name: "b", Value: "2", MaxAge: -1}))
+	h := headers.NewHeaders()
+	h.Set("Content-Length", "0")
+	require.NoError(t, w.WriteHeaders(h))
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"content-length: 0\r\n"+
+			"set-cookie: a=1; Path=/; HttpOnly\r\n"+
+			"set-cookie: b=2; Max-Age=0\r\n\r\n",
+		buf.String())
+
+	// Test: After the headers
+	require.ErrorIs(t, w.SetCookie(&headers.Cookie{Name: "c", Value: "3"}), ErrOutOfOrder)
+
+	// Test: Invalid cookie
+	w = NewWriter(&bytes.Buffer{})
+	require.ErrorIs(t, w.SetCookie(&headers.Cookie{Name: "c", Value: "a b"}), headers.ErrInvalidCookieValue)
+
+	// Test: ResponseWriter
+	buf = &bytes.Buffer{}
+	rw := NewResponseWriter(NewWriter(buf))
+	require.NoError(t, rw.SetCookie(&headers.Cookie{Name: "id", Value: "42"}))
+	_, err := rw.Write([]byte("ok"))
+	require.NoError(t, err)
+	require.NoError(t, rw.Close())
+	assert.Contains(t, buf.String(), "set-cookie: id=42\r\n\r\nok")
+	require.ErrorIs(t, rw.SetCookie(&headers.Cookie{Name: "x", Value: "y"}), ErrOutOfOrder)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"http/internal/request"
+)
+
+// maxWatchBuffer bounds the bytes a disconnectWatcher keeps for a possible
+// hijack. Once reached the watcher stops reading.
+const maxWatchBuffer = 64 << 10
+
+// disconnectWatcher cancels a request's context when the client closes the
+// connection, so that streaming handlers can stop. Only requests without a
+// body are watched, since the handler owns the connection while it reads
+// the body.
+type disconnectWatcher struct {
+	conn   net.Conn
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	stopping bool
+	buf      []byte
+}
+
+func watchDisconnect(conn net.Conn, r *request.Request) *disconnectWatcher {
+	ctx, cancel := context.WithCancel(r.Context())
+	r.SetContext(ctx)
+	dw := &disconnectWatcher{conn: conn, cancel: cancel}
+	if n, _, _ := r.Headers.ContentLength(); n > 0 || r.Headers.Get("Transfer-Encoding") != "" {
+		return dw
+	}
+	dw.done = make(chan struct{})
+	go dw.watch()
+	return dw
+}
+
+func (dw *disconnectWatcher) watch() {
+	defer close(dw.done)
+	buf := make([]byte, 512)
+	for {
+		n, err := dw.conn.Read(buf)
+		dw.mu.Lock()
+		dw.buf = append(dw.buf, buf[:n]...)
+		stopping, full := dw.stopping, len(dw.buf) >= maxWatchBuffer
+		dw.mu.Unlock()
+		if err != nil {
+			if !stopping {
+				dw.cancel()
+			}
+			return
+		}
+		if full {
+			return
+		}
+	}
+}
+
+// stop stops reading from the connection without cancelling the context
+// and returns the bytes read so far.
+func (dw *disconnectWatcher) stop() []byte {
+	if dw.done == nil {
+		return nil
+	}
+	dw.mu.Lock()
+	dw.stopping = true
+	dw.mu.Unlock()
+	// unblock the pending Read
+	dw.conn.SetReadDeadline(time.Unix(1, 0))
+	<-dw.done
+	dw.conn.SetReadDeadline(time.Time{})
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	return dw.buf
+}
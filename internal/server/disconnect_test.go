@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/request"
+)
+
+func TestWatchDisconnect(t *testing.T) {
+	// Test: Closing the client side cancels the context
+	client, conn := net.Pipe()
+	defer conn.Close()
+	r, err := request.RequestFromReader(strings.NewReader("GET /events HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+	dw := watchDisconnect(conn, r)
+	defer dw.cancel()
+	assert.NoError(t, r.Context().Err())
+	client.Close()
+	select {
+	case <-r.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("context not cancelled after disconnect")
+	}
+
+	// Test: Requests with a body are not watched
+	client, conn = net.Pipe()
+	defer conn.Close()
+	r, err = request.RequestHeadersFromReader(strings.NewReader("POST / HTTP/1.1\r\nContent-Length: 2\r\n\r\nhi"))
+	require.NoError(t, err)
+	dw = watchDisconnect(conn, r)
+	client.Close()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, r.Context().Err())
+	assert.Nil(t, dw.stop())
+	dw.cancel()
+	assert.Error(t, r.Context().Err())
+
+	// Test: Stopping keeps the bytes read and the context alive
+	client, conn = net.Pipe()
+	defer conn.Close()
+	defer client.Close()
+	r, err = request.RequestFromReader(strings.NewReader("GET /chat HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+	dw = watchDisconnect(conn, r)
+	_, err = client.Write([]byte("early"))
+	require.NoError(t, err)
+	assert.Equal(t, "early", string(dw.stop()))
+	assert.NoError(t, r.Context().Err())
+	go client.Write([]byte("later"))
+	buf := make([]byte, 5)
+	_, err = conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "later", string(buf))
+}
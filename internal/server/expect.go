@@ -0,0 +1,46 @@
+package server
+
+import (
+	"io"
+	"strings"
+
+	"http/internal/request"
+	"http/internal/response"
+)
+
+// continueReader sends "100 Continue" the first time the handler reads the
+// body, so clients waiting on Expect: 100-continue start uploading.
+type continueReader struct {
+	r    io.Reader
+	w    *response.Writer
+	sent bool
+}
+
+func (c *continueReader) Read(p []byte) (int, error) {
+	if !c.sent {
+		c.sent = true
+		if err := c.w.WriteContinue(); err != nil {
+			return 0, err
+		}
+	}
+	return c.r.Read(p)
+}
+
+// handleExpect checks the Expect header. It reports false when the
+// expectation cannot be met and a 417 has been written instead.
+func handleExpect(w *response.Writer, r *request.Request) bool {
+	expect := r.Headers.Get("Expect")
+	if expect == "" {
+		return true
+	}
+	if !strings.EqualFold(strings.TrimSpace(expect), "100-continue") {
+		he := &HandlerError{
+			StatusCode: int(response.StatusExpectationFailed),
+			Message:    "Expectation Failed\n",
+		}
+		he.Write(w)
+		return false
+	}
+	r.SetBodyReader(&continueReader{r: r.BodyReader(), w: w})
+	return true
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/request"
+	"http/internal/response"
+)
+
+func TestHandleExpect(t *testing.T) {
+	// Test: 100 Continue is sent on first body read
+	r, err := request.RequestHeadersFromReader(strings.NewReader(
+		"POST /upload HTTP/1.1\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\nhello"))
+	require.NoError(t, err)
+	out := &bytes.Buffer{}
+	w := response.NewWriter(out)
+	require.True(t, handleExpect(w, r))
+	assert.Empty(t, out.String())
+	body, err := io.ReadAll(r.BodyReader())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, "HTTP/1.1 100 Continue\r\n\r\n", out.String())
+
+	// Test: Early rejection skips 100 Continue
+	r, err = request.RequestHeadersFromReader(strings.NewReader(
+		"POST /upload HTTP/1.1\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\nhello"))
+	require.NoError(t, err)
+	out = &bytes.Buffer{}
+	w = response.NewWriter(out)
+	require.True(t, handleExpect(w, r))
+	require.NoError(t, w.WriteStatusLine(response.StatusRequestEntityTooLarge))
+	_, err = io.ReadAll(r.BodyReader())
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 413 Content Too Large\r\n", out.String())
+
+	// Test: Unsupported expectation
+	r, err = request.RequestHeadersFromReader(strings.NewReader(
+		"POST /upload HTTP/1.1\r\nExpect: something-else\r\n\r\n"))
+	require.NoError(t, err)
+	out = &bytes.Buffer{}
+	w = response.NewWriter(out)
+	require.False(t, handleExpect(w, r))
+	assert.True(t, strings.HasPrefix(out.String(), "HTTP/1.1 417 Expectation Failed\r\n"))
+}
@@ -7,6 +7,17 @@ import (
 
 type Handler func(w *response.Writer, req *request.Request)
 
+// Middleware wraps a Handler with extra behaviour.
+type Middleware func(next Handler) Handler
+
+// Chain applies middlewares to h, the first one being the outermost.
+func Chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
 type HandlerError struct {
 	StatusCode int
 	Message    string
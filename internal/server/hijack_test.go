@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/request"
+	"http/internal/response"
+)
+
+type hijackResult struct {
+	conn     net.Conn
+	buffered []byte
+	err      error
+}
+
+func TestHijack(t *testing.T) {
+	hijacked := make(chan hijackResult, 1)
+	s, err := Serve(0, func(w *response.Writer, r *request.Request) {
+		conn, buffered, err := w.Hijack()
+		hijacked <- hijackResult{conn, buffered, err}
+		_, _, err = w.Hijack()
+		assert.ErrorIs(t, err, response.ErrHijacked)
+		assert.ErrorIs(t, w.WriteStatusLine(response.StatusOk), response.ErrOutOfOrder)
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	client, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Test: Bytes after the request are handed to the handler
+	_, err = client.Write([]byte("GET /tunnel HTTP/1.1\r\nHost: localhost\r\n\r\nhello"))
+	require.NoError(t, err)
+	res := <-hijacked
+	require.NoError(t, res.err)
+	conn := res.conn
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	got := res.buffered
+	for len(got) < len("hello") {
+		buf := make([]byte, 16)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		got = append(got, buf[:n]...)
+	}
+	assert.Equal(t, "hello", string(got))
+
+	// Test: The connection outlives the handler and nothing else is written
+	time.Sleep(20 * time.Millisecond)
+	_, err = client.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+	_, err = conn.Write([]byte("pong"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(client, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(buf))
+}
+
+func TestHijackUnsupported(t *testing.T) {
+	w := response.NewWriter(&bytes.Buffer{})
+	_, _, err := w.Hijack()
+	require.ErrorIs(t, err, response.ErrNotHijackable)
+	assert.False(t, w.Hijacked())
+}
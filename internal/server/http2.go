@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+
+	"http/internal/http2"
+	"http/internal/request"
+	"http/internal/response"
+)
+
+// ServeTLS is Serve over TLS. Unless config sets NextProtos, clients choose
+// between HTTP/2 and HTTP/1.1 with ALPN.
+func ServeTLS(port int, handler Handler, config *tls.Config) (*Server, error) {
+	config = config.Clone()
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	listener, err := tls.Listen("tcp", fmt.Sprintf(":%d", port), config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		handler:  handler,
+	}
+	go s.listen()
+	return s, nil
+}
+
+// SetH2C makes the server accept cleartext HTTP/2, from clients with prior
+// knowledge that start with the HTTP/2 connection preface and from HTTP/1.1
+// requests offering to upgrade to h2c.
+func (s *Server) SetH2C(enabled bool) {
+	s.h2c.Store(enabled)
+	if enabled {
+		s.HandleUpgrade("h2c", s.upgradeH2C)
+		return
+	}
+	s.upgradeMu.Lock()
+	defer s.upgradeMu.Unlock()
+	delete(s.upgrades, "h2c")
+}
+
+// negotiateHTTP2 completes the TLS handshake of conn, if it uses TLS, and
+// looks for the preface of HTTP/2 with prior knowledge when h2c is enabled.
+// It reports whether conn speaks HTTP/2 and returns the bytes it read.
+func (s *Server) negotiateHTTP2(conn net.Conn) (buffered []byte, h2 bool, err error) {
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err := tc.Handshake(); err != nil {
+			return nil, false, err
+		}
+		return nil, tc.ConnectionState().NegotiatedProtocol == "h2", nil
+	}
+	if !s.h2c.Load() {
+		return nil, false, nil
+	}
+	buf := make([]byte, 0, len(http2.ClientPreface))
+	for len(buf) < cap(buf) {
+		n, err := conn.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if !strings.HasPrefix(http2.ClientPreface, string(buf)) {
+			return buf, false, nil
+		}
+		if err != nil {
+			return buf, false, err
+		}
+	}
+	return buf, true, nil
+}
+
+// upgradeH2C switches an HTTP/1.1 connection to HTTP/2 (RFC 7540 section
+// 3.2). The request is answered on stream 1.
+func (s *Server) upgradeH2C(w *response.Writer, r *request.Request) {
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(r.Headers.Get("HTTP2-Settings"), "="))
+	var settings []http2.Setting
+	if err == nil {
+		settings, err = http2.ParseSettings(payload)
+	}
+	if err != nil {
+		he := &HandlerError{
+			StatusCode: int(response.StatusBadRequest),
+			Message:    "Invalid HTTP2-Settings\n",
+		}
+		he.Write(w)
+		return
+	}
+	// the body has to arrive before the client switches to HTTP/2
+	if _, err := r.ReadBody(); err != nil {
+		return
+	}
+	if err := w.SwitchProtocols("h2c", nil); err != nil {
+		return
+	}
+	conn, buffered, err := w.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for _, name := range []string{"Connection", "Upgrade", "HTTP2-Settings"} {
+		r.Headers.Remove(name)
+	}
+	http2.ServeUpgrade(conn, buffered, settings, r, http2.Handler(s.handler))
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/http2"
+	"http/internal/request"
+	"http/internal/response"
+)
+
+func protoHandler(w *response.Writer, r *request.Request) {
+	body, err := r.ReadBody()
+	if err != nil {
+		return
+	}
+	out := []byte(r.RequestLine.Method + " " + r.RequestLine.RequestTarget + " HTTP/" +
+		r.RequestLine.HttpVersion + " " + string(body))
+	w.WriteStatusLine(response.StatusOk)
+	w.WriteHeaders(response.GetDefaultHeaders(len(out)))
+	w.WriteBody(out)
+}
+
+// selfSigned returns a certificate for localhost and a pool trusting it.
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func TestHTTP2TLS(t *testing.T) {
+	cert, pool := selfSigned(t)
+	s, err := ServeTLS(0, protoHandler, &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer s.Close()
+	_, port, err := net.SplitHostPort(s.Addr().String())
+	require.NoError(t, err)
+
+	get := func(protos *http.Protocols) *http.Response {
+		t.Helper()
+		transport := &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+			Protocols:       protos,
+		}
+		defer transport.CloseIdleConnections()
+		res, err := (&http.Client{Transport: transport}).Post("https://localhost:"+port+"/tls",
+			"text/plain", strings.NewReader("body"))
+		require.NoError(t, err)
+		return res
+	}
+
+	// Test: ALPN selects HTTP/2
+	protos := &http.Protocols{}
+	protos.SetHTTP2(true)
+	res := get(protos)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.ProtoMajor)
+	assert.Equal(t, "POST /tls HTTP/2 body", string(body))
+
+	// Test: HTTP/1.1 clients still work
+	protos = &http.Protocols{}
+	protos.SetHTTP1(true)
+	res = get(protos)
+	body, err = io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.ProtoMajor)
+	assert.Equal(t, "POST /tls HTTP/1.1 body", string(body))
+}
+
+func TestH2CPriorKnowledge(t *testing.T) {
+	s, err := Serve(0, protoHandler)
+	require.NoError(t, err)
+	defer s.Close()
+	s.SetH2C(true)
+
+	protos := &http.Protocols{}
+	protos.SetUnencryptedHTTP2(true)
+	transport := &http.Transport{Protocols: protos}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+	_, port, err := net.SplitHostPort(s.Addr().String())
+	require.NoError(t, err)
+
+	// Test: Several requests share the connection
+	for _, path := range []string{"/one", "/two"} {
+		res, err := client.Get("http://localhost:" + port + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, 2, res.ProtoMajor)
+		assert.Equal(t, "GET "+path+" HTTP/2 ", string(body))
+	}
+
+	// Test: HTTP/1.1 on the same port
+	res, err := http.Get("http://localhost:" + port + "/plain")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "GET /plain HTTP/1.1 ", string(body))
+}
+
+func TestH2CUpgrade(t *testing.T) {
+	s, err := Serve(0, protoHandler)
+	require.NoError(t, err)
+	defer s.Close()
+	s.SetH2C(true)
+
+	// Test: The upgraded request is answered on stream 1
+	conn, head, br := roundTrip(t, s.Addr().String(), "POST /up HTTP/1.1\r\nHost: localhost\r\n"+
+		"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQAAP__\r\n"+
+		"Content-Length: 4\r\n\r\nbody")
+	assert.Equal(t, "HTTP/1.1 101 Switching Protocols\r\nconnection: Upgrade\r\nupgrade: h2c\r\n\r\n", head)
+	_, err = conn.Write([]byte(http2.ClientPreface))
+	require.NoError(t, err)
+	require.NoError(t, http2.WriteFrame(conn, http2.FrameSettings, 0, 0, nil))
+	var headers, data []byte
+	for {
+		f, err := http2.ReadFrame(br, 1<<14)
+		require.NoError(t, err)
+		if f.StreamID != 1 {
+			continue
+		}
+		switch f.Type {
+		case http2.FrameHeaders:
+			headers = f.Payload
+		case http2.FrameData:
+			data = append(data, f.Payload...)
+		}
+		if f.Flags.Has(http2.FlagEndStream) {
+			break
+		}
+	}
+	assert.NotEmpty(t, headers)
+	assert.Equal(t, "POST /up HTTP/1.1 body", string(data))
+
+	// Test: Invalid HTTP2-Settings
+	_, head, _ = roundTrip(t, s.Addr().String(), "GET / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAQ\r\n\r\n")
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 400 "), head)
+
+	// Test: Disabled h2c leaves the upgrade unanswered
+	s.SetH2C(false)
+	_, head, _ = roundTrip(t, s.Addr().String(), "GET / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABk\r\n\r\n")
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 200 "), head)
+}
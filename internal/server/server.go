@@ -1,11 +1,17 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"slices"
+	"sync"
 	"sync/atomic"
 
+	"http/internal/headers"
+	"http/internal/http2"
 	"http/internal/request"
 	"http/internal/response"
 )
@@ -13,7 +19,12 @@ import (
 type Server struct {
 	handler  Handler
 	closed   atomic.Bool
+	lenient  atomic.Bool
+	h2c      atomic.Bool
 	listener net.Listener
+
+	upgradeMu sync.RWMutex
+	upgrades  map[string]Handler
 }
 
 func Serve(port int, handler Handler) (*Server, error) {
@@ -30,6 +41,11 @@ func Serve(port int, handler Handler) (*Server, error) {
 	return s, nil
 }
 
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
 func (s *Server) Close() error {
 	s.closed.Store(true)
 	if s.listener != nil {
@@ -38,6 +54,12 @@ func (s *Server) Close() error {
 	return nil
 }
 
+// SetLenient makes the server accept requests with obs-fold continuation
+// lines and bare LF line endings. Requests parsed leniently are logged.
+func (s *Server) SetLenient(lenient bool) {
+	s.lenient.Store(lenient)
+}
+
 func (s *Server) listen() {
 	for {
 		conn, err := s.listener.Accept()
@@ -54,9 +76,54 @@ func (s *Server) listen() {
 }
 
 func (s *Server) handle(conn net.Conn) {
-	defer fmt.Println("Connection closed with: ", conn.RemoteAddr())
-	defer conn.Close()
-	r, _ := request.RequestFromReader(conn)
+	hijacked := false
+	defer func() {
+		if hijacked {
+			return
+		}
+		conn.Close()
+		fmt.Println("Connection closed with: ", conn.RemoteAddr())
+	}()
+	buffered, h2, err := s.negotiateHTTP2(conn)
+	if err != nil {
+		return
+	}
+	if h2 {
+		http2.ServeConn(conn, buffered, http2.Handler(s.handler))
+		return
+	}
 	w := response.NewWriter(conn)
-	s.handler(w, r)
+	mode := headers.Strict
+	if s.lenient.Load() {
+		mode = headers.Lenient
+	}
+	reader := io.MultiReader(bytes.NewReader(buffered), conn)
+	r, err := request.RequestHeadersFromReaderMode(reader, mode)
+	if err != nil {
+		he := &HandlerError{
+			StatusCode: int(response.StatusBadRequest),
+			Message:    err.Error(),
+		}
+		he.Write(w)
+		return
+	}
+	if r.Leniencies != 0 {
+		log.Printf("Lenient parsing for %s: %s\n", conn.RemoteAddr(), r.Leniencies)
+	}
+	w.SetRequestMethod(r.RequestLine.Method)
+	if !handleExpect(w, r) {
+		return
+	}
+	dw := watchDisconnect(conn, r)
+	defer dw.cancel()
+	w.SetHijacker(func() (net.Conn, []byte, error) {
+		hijacked = true
+		return conn, slices.Concat(r.Buffered(), dw.stop()), nil
+	})
+	handler := s.handler
+	if h := s.upgradeHandler(r); h != nil {
+		handler = h
+	}
+	handler(w, r)
+	w.Finish()
 }
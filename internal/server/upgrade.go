@@ -0,0 +1,74 @@
+package server
+
+import (
+	"strings"
+
+	"http/internal/request"
+	"http/internal/response"
+)
+
+// HandleUpgrade registers h for requests offering to switch to protocol, such
+// as "websocket" or "h2c". Such requests go to h instead of the server's
+// handler. h either switches with response.Writer.SwitchProtocols and
+// hijacks the connection or answers like any other handler. When the client
+// offers several registered protocols, its first preference wins.
+func (s *Server) HandleUpgrade(protocol string, h Handler) {
+	s.upgradeMu.Lock()
+	defer s.upgradeMu.Unlock()
+	if s.upgrades == nil {
+		s.upgrades = map[string]Handler{}
+	}
+	s.upgrades[strings.ToLower(protocol)] = h
+}
+
+// upgradeHandler returns the handler registered for the protocol the client
+// prefers, or nil when the request is not upgraded.
+func (s *Server) upgradeHandler(r *request.Request) Handler {
+	s.upgradeMu.RLock()
+	defer s.upgradeMu.RUnlock()
+	for _, p := range r.UpgradeProtocols() {
+		p = strings.ToLower(p)
+		h, ok := s.upgrades[p]
+		if !ok {
+			continue
+		}
+		if p == "h2c" && !h2cOffered(r) {
+			continue
+		}
+		return h
+	}
+	return nil
+}
+
+// h2cOffered checks the extra requirements of an h2c upgrade: exactly one
+// HTTP2-Settings header, itself listed in Connection (RFC 7540 section
+// 3.2). Clients not meeting them are answered over HTTP/1.1.
+func h2cOffered(r *request.Request) bool {
+	settings := r.Headers.Get("HTTP2-Settings")
+	return settings != "" && !strings.Contains(settings, ",") &&
+		r.Headers.HasConnectionToken("http2-settings")
+}
+
+// RequireUpgrade returns a middleware answering 426 Upgrade Required to
+// requests that do not offer one of protocols, such as a WebSocket endpoint
+// being fetched as a plain page.
+func RequireUpgrade(protocols ...string) Middleware {
+	return func(next Handler) Handler {
+		return func(w *response.Writer, r *request.Request) {
+			for _, p := range protocols {
+				if r.OffersUpgrade(p) {
+					next(w, r)
+					return
+				}
+			}
+			message := "Upgrade Required\n"
+			h := response.GetDefaultHeaders(len(message))
+			h.Override("Upgrade", strings.Join(protocols, ", "))
+			// this server closes the connection after every response
+			h.Override("Connection", "Upgrade, close")
+			w.WriteStatusLine(response.StatusUpgradeRequired)
+			w.WriteHeaders(h)
+			w.WriteBody([]byte(message))
+		}
+	}
+}
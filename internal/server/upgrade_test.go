@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/request"
+	"http/internal/response"
+)
+
+func switchAndEcho(protocol string) Handler {
+	return func(w *response.Writer, r *request.Request) {
+		if err := w.SwitchProtocols(protocol, nil); err != nil {
+			return
+		}
+		conn, buffered, err := w.Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(buffered)
+		io.Copy(conn, conn)
+	}
+}
+
+// roundTrip sends a raw request and returns the response head along with a
+// reader for whatever follows it.
+func roundTrip(t *testing.T, addr, req string) (net.Conn, string, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write([]byte(req))
+	require.NoError(t, err)
+	br := bufio.NewReader(conn)
+	var head strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		head.WriteString(line)
+		if line == "\r\n" {
+			return conn, head.String(), br
+		}
+	}
+}
+
+func TestHandleUpgrade(t *testing.T) {
+	s, err := Serve(0, func(w *response.Writer, r *request.Request) {
+		body := []byte("plain")
+		w.WriteStatusLine(response.StatusOk)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody(body)
+	})
+	require.NoError(t, err)
+	defer s.Close()
+	s.HandleUpgrade("Echo", switchAndEcho("echo"))
+	s.HandleUpgrade("h2c", switchAndEcho("h2c"))
+	addr := s.Addr().String()
+
+	// Test: First registered protocol in the client's order is used
+	conn, head, br := roundTrip(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Connection: keep-alive, Upgrade\r\nUpgrade: foo/2, echo, h2c\r\n\r\nearly")
+	assert.Equal(t, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"connection: Upgrade\r\n"+
+		"upgrade: echo\r\n\r\n", head)
+	_, err = conn.Write([]byte(" bird"))
+	require.NoError(t, err)
+	buf := make([]byte, len("early bird"))
+	_, err = io.ReadFull(br, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "early bird", string(buf))
+
+	// Test: Upgrade not listed in Connection is ignored
+	_, head, _ = roundTrip(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: echo\r\n\r\n")
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 200 OK\r\n"), head)
+
+	// Test: Unregistered protocols fall through to the handler
+	_, head, _ = roundTrip(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Connection: Upgrade\r\nUpgrade: foo\r\n\r\n")
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 200 OK\r\n"), head)
+
+	// Test: h2c needs HTTP2-Settings
+	_, head, _ = roundTrip(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Connection: Upgrade\r\nUpgrade: h2c\r\n\r\n")
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 200 OK\r\n"), head)
+	_, head, _ = roundTrip(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQAoAAAAAIAAAAA\r\n\r\n")
+	assert.Contains(t, head, "upgrade: h2c\r\n")
+}
+
+func TestRequireUpgrade(t *testing.T) {
+	called := false
+	h := RequireUpgrade("websocket", "h2c")(func(w *response.Writer, r *request.Request) {
+		called = true
+	})
+
+	// Test: Request offering an upgrade reaches the handler
+	r, err := request.RequestFromReader(strings.NewReader(
+		"GET /chat HTTP/1.1\r\nConnection: Upgrade\r\nUpgrade: WebSocket\r\n\r\n"))
+	require.NoError(t, err)
+	out := &bytes.Buffer{}
+	h(response.NewWriter(out), r)
+	assert.True(t, called)
+	assert.Empty(t, out.String())
+
+	// Test: Plain request gets 426
+	called = false
+	r, err = request.RequestFromReader(strings.NewReader("GET /chat HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+	out = &bytes.Buffer{}
+	h(response.NewWriter(out), r)
+	assert.False(t, called)
+	assert.True(t, strings.HasPrefix(out.String(), "HTTP/1.1 426 Upgrade Required\r\n"), out.String())
+	assert.Contains(t, out.String(), "connection: Upgrade, close\r\n")
+	assert.Contains(t, out.String(), "upgrade: websocket, h2c\r\n")
+}
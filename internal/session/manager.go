@@ -0,0 +1,299 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"http/internal/headers"
+	"http/internal/request"
+	"http/internal/response"
+	"http/internal/server"
+)
+
+const (
+	DefaultCookieName = "session"
+	DefaultMaxAge     = 24 * time.Hour
+	// MinKeySize is the smallest secret key accepted by New.
+	MinKeySize = 32
+	// maxCookieSize is the largest cookie browsers are required to store.
+	maxCookieSize = 4096
+)
+
+var (
+	ErrNoKeys         = errors.New("session: no keys")
+	ErrShortKey       = errors.New("session: key shorter than 32 bytes")
+	ErrCookieTooLarge = errors.New("session: cookie too large")
+)
+
+// Manager is a middleware loading and saving sessions. Sessions are kept in
+// an HMAC-signed cookie, optionally encrypted with AES-GCM, or in a Store
+// with only the signed session ID in the cookie.
+type Manager struct {
+	CookieName string
+	Path       string
+	Domain     string
+	Secure     bool
+	SameSite   headers.SameSite
+	// MaxAge is how long a session lives without being modified. Sessions
+	// past half their lifetime are renewed on use.
+	MaxAge time.Duration
+	// Encrypt hides cookie session values from the client. It has no
+	// effect when Store is set.
+	Encrypt bool
+	// Store keeps session values on the server. When nil the values are
+	// kept in the cookie.
+	Store Store
+
+	keys []derivedKey
+}
+
+type derivedKey struct {
+	sign    []byte
+	encrypt cipher.AEAD
+}
+
+// New returns a Manager signing cookies with the given keys. The first key
+// signs and encrypts new cookies; the others are only used to read existing
+// ones, so that keys can be rotated by prepending a new key and dropping the
+// oldest once its cookies have expired.
+func New(keys ...[]byte) (*Manager, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	m := &Manager{
+		CookieName: DefaultCookieName,
+		Path:       "/",
+		SameSite:   headers.SameSiteLax,
+		MaxAge:     DefaultMaxAge,
+	}
+	for _, key := range keys {
+		if len(key) < MinKeySize {
+			return nil, ErrShortKey
+		}
+		block, err := aes.NewCipher(derive(key, "encrypt"))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		m.keys = append(m.keys, derivedKey{sign: derive(key, "sign"), encrypt: aead})
+	}
+	return m, nil
+}
+
+func derive(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("session " + purpose))
+	return mac.Sum(nil)
+}
+
+// Wrap returns a Handler that loads the session before calling next and
+// saves it just before the status line is written. Handlers reach the
+// session with Get. When the session cannot be saved, WriteStatusLine fails
+// and the request is answered with 500 unless the handler responds itself.
+func (m *Manager) Wrap(next server.Handler) server.Handler {
+	return func(w *response.Writer, r *request.Request) {
+		s := m.load(r)
+		withSession(r, s)
+		var saveErr error
+		saved := false
+		w.OnWriteStatusLine(func(response.StatusCode) error {
+			if saved {
+				return nil
+			}
+			saved = true
+			saveErr = m.save(w, s)
+			return saveErr
+		})
+		next(w, r)
+		if saveErr != nil && !w.StatusWritten() {
+			he := &server.HandlerError{
+				StatusCode: int(response.StatusInternalServerError),
+				Message:    "session could not be saved\n",
+			}
+			he.Write(w)
+		}
+	}
+}
+
+// cookieSession is the signed payload of a cookie session.
+type cookieSession struct {
+	ID      string            `json:"i"`
+	Values  map[string]string `json:"v"`
+	Expires int64             `json:"e"`
+}
+
+func (m *Manager) load(r *request.Request) *Session {
+	c, ok := r.Cookie(m.CookieName)
+	if !ok {
+		return newSession()
+	}
+	payload, keyIndex, ok := m.verify(c.Value)
+	if !ok {
+		return newSession()
+	}
+	s := &Session{fromReq: true}
+	if m.Store != nil {
+		// unknown IDs are never adopted, so clients cannot pick their own
+		values, expires, ok, err := m.Store.Load(string(payload))
+		if err != nil || !ok {
+			return newSession()
+		}
+		s.id, s.values, s.expires = string(payload), values, expires
+	} else {
+		if m.Encrypt {
+			payload, ok = m.decrypt(payload, keyIndex)
+			if !ok {
+				return newSession()
+			}
+		}
+		var cs cookieSession
+		if err := json.Unmarshal(payload, &cs); err != nil || cs.ID == "" {
+			return newSession()
+		}
+		s.id, s.values, s.expires = cs.ID, cs.Values, time.Unix(cs.Expires, 0)
+		if s.values == nil {
+			s.values = map[string]string{}
+		}
+	}
+	if !time.Now().Before(s.expires) {
+		return newSession()
+	}
+	// reissue cookies signed with an old key or close to expiry
+	if keyIndex > 0 || time.Until(s.expires) < m.MaxAge/2 {
+		s.modified = true
+	}
+	return s
+}
+
+func (m *Manager) save(w *response.Writer, s *Session) error {
+	if s.destroyed {
+		if m.Store != nil {
+			for _, id := range []string{s.oldID, s.id} {
+				if id == "" {
+					continue
+				}
+				if err := m.Store.Delete(id); err != nil {
+					return err
+				}
+			}
+		}
+		if !s.fromReq {
+			return nil
+		}
+		return w.SetCookie(m.cookie("", -1))
+	}
+	if !s.modified {
+		return nil
+	}
+	s.expires = time.Now().Add(m.MaxAge).Truncate(time.Second)
+	var payload []byte
+	if m.Store != nil {
+		if s.oldID != "" {
+			if err := m.Store.Delete(s.oldID); err != nil {
+				return err
+			}
+		}
+		if err := m.Store.Save(s.id, s.values, s.expires); err != nil {
+			return err
+		}
+		payload = []byte(s.id)
+	} else {
+		data, err := json.Marshal(cookieSession{ID: s.id, Values: s.values, Expires: s.expires.Unix()})
+		if err != nil {
+			return err
+		}
+		payload = data
+		if m.Encrypt {
+			payload = m.encrypt(payload)
+		}
+	}
+	value := m.sign(payload)
+	if len(m.CookieName)+len(value) > maxCookieSize {
+		return ErrCookieTooLarge
+	}
+	c := m.cookie(value, int(m.MaxAge/time.Second))
+	c.Expires = s.expires
+	return w.SetCookie(c)
+}
+
+func (m *Manager) cookie(value string, maxAge int) *headers.Cookie {
+	return &headers.Cookie{
+		Name:     m.CookieName,
+		Value:    value,
+		Path:     m.Path,
+		Domain:   m.Domain,
+		MaxAge:   maxAge,
+		Secure:   m.Secure,
+		HttpOnly: true,
+		SameSite: m.SameSite,
+	}
+}
+
+// sign returns the cookie value "payload.mac", both base64url encoded. The
+// MAC covers the cookie name so values cannot be moved between cookies.
+func (m *Manager) sign(payload []byte) string {
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(m.mac(m.keys[0], encoded))
+}
+
+// verify checks the cookie value against every key and returns the payload
+// and the index of the key that signed it.
+func (m *Manager) verify(value string) ([]byte, int, bool) {
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, 0, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, 0, false
+	}
+	for i, key := range m.keys {
+		if hmac.Equal(mac, m.mac(key, encoded)) {
+			payload, err := base64.RawURLEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, 0, false
+			}
+			return payload, i, true
+		}
+	}
+	return nil, 0, false
+}
+
+func (m *Manager) mac(key derivedKey, encoded string) []byte {
+	mac := hmac.New(sha256.New, key.sign)
+	mac.Write([]byte(m.CookieName + "=" + encoded))
+	return mac.Sum(nil)
+}
+
+func (m *Manager) encrypt(plaintext []byte) []byte {
+	aead := m.keys[0].encrypt
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(m.CookieName))
+}
+
+func (m *Manager) decrypt(ciphertext []byte, keyIndex int) ([]byte, bool) {
+	aead := m.keys[keyIndex].encrypt
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, false
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(m.CookieName))
+	if err != nil {
+		return nil, false
+	}
+	return plaintext, true
+}
@@ -0,0 +1,101 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"http/internal/request"
+)
+
+type contextKey struct{}
+
+// Session holds the values of one client's session. It is only valid for
+// the duration of the request it was loaded for.
+type Session struct {
+	id        string
+	oldID     string
+	values    map[string]string
+	expires   time.Time
+	modified  bool
+	destroyed bool
+	fromReq   bool
+}
+
+// Get returns the session attached to the request by Manager.Wrap, or nil
+// if the request did not pass through the session middleware.
+func Get(r *request.Request) *Session {
+	s, _ := r.Context().Value(contextKey{}).(*Session)
+	return s
+}
+
+func newSession() *Session {
+	return &Session{
+		id:     newID(),
+		values: map[string]string{},
+	}
+}
+
+// ID returns the session identifier. It changes when the session is renewed.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsNew reports whether the session was created for this request rather
+// than loaded from the client's cookie.
+func (s *Session) IsNew() bool {
+	return !s.fromReq
+}
+
+// Expires returns when the session expires, or the zero time for a session
+// that has not been saved yet.
+func (s *Session) Expires() time.Time {
+	return s.expires
+}
+
+func (s *Session) Get(key string) string {
+	return s.values[key]
+}
+
+func (s *Session) Set(key, value string) {
+	s.values[key] = value
+	s.modified = true
+}
+
+func (s *Session) Delete(key string) {
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.modified = true
+	}
+}
+
+// Renew gives the session a new identifier while keeping its values. Call
+// it whenever the privilege level changes, such as on login, so that an
+// identifier planted by an attacker before login is never authenticated.
+func (s *Session) Renew() {
+	if s.oldID == "" && s.fromReq {
+		s.oldID = s.id
+	}
+	s.id = newID()
+	s.modified = true
+}
+
+// Destroy deletes the session's values and removes its cookie, for example
+// on logout.
+func (s *Session) Destroy() {
+	s.values = map[string]string{}
+	s.destroyed = true
+}
+
+func withSession(r *request.Request, s *Session) {
+	r.SetContext(context.WithValue(r.Context(), contextKey{}, s))
+}
+
+func newID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,266 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/request"
+	"http/internal/response"
+	"http/internal/server"
+)
+
+var (
+	key1 = bytes.Repeat([]byte{1}, 32)
+	key2 = bytes.Repeat([]byte{2}, 32)
+)
+
+// run sends a request carrying cookie through m and returns the value of
+// the session cookie set by the response, or "" if none was set.
+func run(t *testing.T, m *Manager, cookie string, h server.Handler) string {
+	t.Helper()
+	req := "GET / HTTP/1.1\r\n"
+	if cookie != "" {
+		req += "Cookie: " + m.CookieName + "=" + cookie + "\r\n"
+	}
+	r, err := request.RequestFromReader(strings.NewReader(req + "\r\n"))
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+	m.Wrap(func(w *response.Writer, r *request.Request) {
+		h(w, r)
+		rw := response.NewResponseWriter(w)
+		rw.Write([]byte("ok"))
+		rw.Close()
+	})(w, r)
+	head, _, _ := strings.Cut(buf.String(), "\r\n\r\n")
+	for _, line := range strings.Split(head, "\r\n") {
+		if value, ok := strings.CutPrefix(line, "set-cookie: "); ok {
+			name, rest, _ := strings.Cut(value, "=")
+			require.Equal(t, m.CookieName, name)
+			value, _, _ = strings.Cut(rest, ";")
+			if value == "" {
+				return "deleted"
+			}
+			return value
+		}
+	}
+	return ""
+}
+
+func TestNew(t *testing.T) {
+	_, err := New()
+	require.ErrorIs(t, err, ErrNoKeys)
+	_, err = New([]byte("short"))
+	require.ErrorIs(t, err, ErrShortKey)
+}
+
+func TestCookieSession(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		m, err := New(key1)
+		require.NoError(t, err)
+		m.Encrypt = encrypt
+
+		// Test: Untouched new sessions set no cookie
+		assert.Equal(t, "", run(t, m, "", func(w *response.Writer, r *request.Request) {
+			assert.True(t, Get(r).IsNew())
+		}))
+
+		// Test: Values round trip through the cookie
+		cookie := run(t, m, "", func(w *response.Writer, r *request.Request) {
+			Get(r).Set("user", "alice")
+		})
+		require.NotEmpty(t, cookie)
+		assert.Equal(t, encrypt, !strings.Contains(cookie, "eyJ"), "payload visible: %s", cookie)
+		assert.Equal(t, "", run(t, m, cookie, func(w *response.Writer, r *request.Request) {
+			assert.False(t, Get(r).IsNew())
+			assert.Equal(t, "alice", Get(r).Get("user"))
+		}))
+
+		// Test: Tampered cookies start a new session
+		tampered := "A" + cookie[1:]
+		if tampered == cookie {
+			tampered = "B" + cookie[1:]
+		}
+		run(t, m, tampered, func(w *response.Writer, r *request.Request) {
+			assert.True(t, Get(r).IsNew())
+			assert.Equal(t, "", Get(r).Get("user"))
+		})
+
+		// Test: Cookies are bound to their name
+		other, err := New(key1)
+		require.NoError(t, err)
+		other.CookieName = "other"
+		other.Encrypt = encrypt
+		run(t, other, cookie, func(w *response.Writer, r *request.Request) {
+			assert.True(t, Get(r).IsNew())
+		})
+
+		// Test: Destroy deletes the cookie
+		assert.Equal(t, "deleted", run(t, m, cookie, func(w *response.Writer, r *request.Request) {
+			Get(r).Destroy()
+		}))
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, err := New(key1)
+	require.NoError(t, err)
+	old.Encrypt = true
+	cookie := run(t, old, "", func(w *response.Writer, r *request.Request) {
+		Get(r).Set("user", "alice")
+	})
+
+	// Test: Cookies signed with an old key are accepted and reissued
+	rotated, err := New(key2, key1)
+	require.NoError(t, err)
+	rotated.Encrypt = true
+	reissued := run(t, rotated, cookie, func(w *response.Writer, r *request.Request) {
+		assert.Equal(t, "alice", Get(r).Get("user"))
+	})
+	require.NotEmpty(t, reissued)
+	assert.NotEqual(t, cookie, reissued)
+
+	// Test: Once the old key is dropped only reissued cookies are valid
+	current, err := New(key2)
+	require.NoError(t, err)
+	current.Encrypt = true
+	run(t, current, cookie, func(w *response.Writer, r *request.Request) {
+		assert.True(t, Get(r).IsNew())
+	})
+	run(t, current, reissued, func(w *response.Writer, r *request.Request) {
+		assert.Equal(t, "alice", Get(r).Get("user"))
+	})
+}
+
+func TestExpiry(t *testing.T) {
+	m, err := New(key1)
+	require.NoError(t, err)
+	cookieExpiring := func(expires time.Time) string {
+		data, err := json.Marshal(cookieSession{
+			ID:      "id",
+			Values:  map[string]string{"user": "alice"},
+			Expires: expires.Unix(),
+		})
+		require.NoError(t, err)
+		return m.sign(data)
+	}
+
+	// Test: Fresh sessions are not reissued
+	assert.Equal(t, "", run(t, m, cookieExpiring(time.Now().Add(DefaultMaxAge)), func(w *response.Writer, r *request.Request) {
+		assert.Equal(t, "alice", Get(r).Get("user"))
+	}))
+
+	// Test: Sessions past half their lifetime are renewed on use
+	renewed := run(t, m, cookieExpiring(time.Now().Add(time.Hour)), func(w *response.Writer, r *request.Request) {
+		assert.Equal(t, "alice", Get(r).Get("user"))
+	})
+	require.NotEmpty(t, renewed)
+	run(t, m, renewed, func(w *response.Writer, r *request.Request) {
+		assert.WithinDuration(t, time.Now().Add(DefaultMaxAge), Get(r).Expires(), 2*time.Second)
+	})
+
+	// Test: Expired sessions are dropped
+	run(t, m, cookieExpiring(time.Now().Add(-time.Second)), func(w *response.Writer, r *request.Request) {
+		assert.True(t, Get(r).IsNew())
+		assert.Equal(t, "", Get(r).Get("user"))
+	})
+}
+
+func TestStoreSession(t *testing.T) {
+	m, err := New(key1)
+	require.NoError(t, err)
+	store := NewMemoryStore()
+	m.Store = store
+
+	// Test: Only the signed ID is sent to the client
+	var id string
+	cookie := run(t, m, "", func(w *response.Writer, r *request.Request) {
+		Get(r).Set("user", "alice")
+		id = Get(r).ID()
+	})
+	require.NotEmpty(t, cookie)
+	assert.Equal(t, 1, store.Len())
+	values, _, ok, err := store.Load(id)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"user": "alice"}, values)
+
+	// Test: Renew on login replaces the ID and deletes the old session
+	var renewedID string
+	renewed := run(t, m, cookie, func(w *response.Writer, r *request.Request) {
+		s := Get(r)
+		assert.Equal(t, id, s.ID())
+		s.Renew()
+		s.Set("role", "admin")
+		renewedID = s.ID()
+	})
+	assert.NotEqual(t, id, renewedID)
+	assert.NotEqual(t, cookie, renewed)
+	_, _, ok, _ = store.Load(id)
+	assert.False(t, ok)
+	run(t, m, cookie, func(w *response.Writer, r *request.Request) {
+		assert.True(t, Get(r).IsNew())
+	})
+	run(t, m, renewed, func(w *response.Writer, r *request.Request) {
+		assert.Equal(t, "admin", Get(r).Get("role"))
+		assert.Equal(t, "alice", Get(r).Get("user"))
+	})
+
+	// Test: Signed but unknown IDs are not adopted
+	planted := m.sign([]byte("attacker-chosen"))
+	run(t, m, planted, func(w *response.Writer, r *request.Request) {
+		assert.True(t, Get(r).IsNew())
+		assert.NotEqual(t, "attacker-chosen", Get(r).ID())
+	})
+
+	// Test: Destroy removes the stored session
+	assert.Equal(t, "deleted", run(t, m, renewed, func(w *response.Writer, r *request.Request) {
+		Get(r).Destroy()
+	}))
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestCookieTooLarge(t *testing.T) {
+	m, err := New(key1)
+	require.NoError(t, err)
+	big := func(w *response.Writer, r *request.Request) {
+		Get(r).Set("big", strings.Repeat("x", maxCookieSize))
+		rw := response.NewResponseWriter(w)
+		rw.Write([]byte("ok"))
+		rw.Close()
+	}
+
+	// Test: Nothing is sent when the status line hook fails
+	r, err := request.RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	m.Wrap(func(w *response.Writer, r *request.Request) {
+		Get(r).Set("big", strings.Repeat("x", maxCookieSize))
+		require.ErrorIs(t, w.WriteStatusLine(response.StatusOk), ErrCookieTooLarge)
+		assert.Empty(t, buf.String())
+	})(response.NewWriter(buf), r)
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 500 "), buf.String())
+
+	// Test: The client gets a complete 500 response without the cookie
+	s, err := server.Serve(0, m.Wrap(big))
+	require.NoError(t, err)
+	defer s.Close()
+	_, port, err := net.SplitHostPort(s.Addr().String())
+	require.NoError(t, err)
+	res, err := http.Get("http://localhost:" + port + "/")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	assert.Empty(t, res.Header.Values("Set-Cookie"))
+	assert.Equal(t, "session could not be saved\n", string(body))
+}
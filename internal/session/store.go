@@ -0,0 +1,83 @@
+package session
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// Store keeps session values on the server, keyed by session ID.
+type Store interface {
+	// Load returns the values of an unexpired session. ok is false when
+	// there is no such session.
+	Load(id string) (values map[string]string, expires time.Time, ok bool, err error)
+	Save(id string, values map[string]string, expires time.Time) error
+	Delete(id string) error
+}
+
+// sweepInterval is how often MemoryStore drops expired sessions.
+const sweepInterval = time.Minute
+
+// MemoryStore is a Store keeping sessions in memory. Sessions are lost when
+// the process exits.
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	values  map[string]string
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: map[string]memoryEntry{},
+	}
+}
+
+func (ms *MemoryStore) Load(id string) (map[string]string, time.Time, bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	e, ok := ms.sessions[id]
+	if !ok {
+		return nil, time.Time{}, false, nil
+	}
+	if !time.Now().Before(e.expires) {
+		delete(ms.sessions, id)
+		return nil, time.Time{}, false, nil
+	}
+	return maps.Clone(e.values), e.expires, true, nil
+}
+
+func (ms *MemoryStore) Save(id string, values map[string]string, expires time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now()
+	if now.Sub(ms.lastSweep) >= sweepInterval {
+		for k, e := range ms.sessions {
+			if !now.Before(e.expires) {
+				delete(ms.sessions, k)
+			}
+		}
+		ms.lastSweep = now
+	}
+	ms.sessions[id] = memoryEntry{values: maps.Clone(values), expires: expires}
+	return nil
+}
+
+func (ms *MemoryStore) Delete(id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.sessions, id)
+	return nil
+}
+
+// Len returns the number of sessions held, including expired sessions that
+// have not been swept yet.
+func (ms *MemoryStore) Len() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return len(ms.sessions)
+}
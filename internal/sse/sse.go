@@ -0,0 +1,190 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"http/internal/headers"
+	"http/internal/request"
+	"http/internal/response"
+)
+
+const DefaultHeartbeat = 15 * time.Second
+
+var (
+	ErrStreamClosed = errors.New("sse: stream closed")
+	ErrInvalidField = errors.New("sse: id and event must not contain line breaks")
+)
+
+// Event is one server-sent event. Empty fields are omitted.
+type Event struct {
+	ID    string
+	Event string
+	// Data may span several lines; each is sent as its own data field.
+	Data string
+	// Retry tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// Stream writes a text/event-stream response. Every event is sent as its
+// own chunk as soon as it is written. The stream ends when Close is called,
+// the handler returns or the client disconnects, after which Send fails with
+// ErrStreamClosed.
+type Stream struct {
+	cw          *response.ChunkedWriter
+	lastEventID string
+
+	mu     sync.Mutex
+	err    error
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// New starts an event stream answering r, sending a heartbeat comment every
+// DefaultHeartbeat.
+func New(w *response.Writer, r *request.Request) (*Stream, error) {
+	return NewWithHeartbeat(w, r, DefaultHeartbeat)
+}
+
+// NewWithHeartbeat starts an event stream sending a heartbeat comment every
+// interval, keeping proxies from timing out idle streams and detecting
+// clients that went away. An interval of zero disables heartbeats.
+func NewWithHeartbeat(w *response.Writer, r *request.Request, interval time.Duration) (*Stream, error) {
+	if err := w.WriteStatusLine(response.StatusOk); err != nil {
+		return nil, err
+	}
+	h := headers.NewHeaders()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Connection", "close")
+	cw, err := response.NewChunkedWriter(w, h)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	s := &Stream{
+		cw:          cw,
+		lastEventID: r.Headers.Get("Last-Event-ID"),
+		done:        make(chan struct{}),
+		cancel:      cancel,
+	}
+	// the heartbeat must stop before the server ends the response, even if
+	// the handler returned without calling Close
+	w.OnFinish(s.Close)
+	go s.run(ctx, interval)
+	return s, nil
+}
+
+// LastEventID returns the ID of the last event the client saw before
+// reconnecting, from the Last-Event-ID header, so the stream can resume.
+func (s *Stream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done is closed when the stream ends.
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Stream) run(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			s.fail(ErrStreamClosed)
+			return
+		case <-tick:
+			if s.Comment("heartbeat") != nil {
+				return
+			}
+		}
+	}
+}
+
+// Send writes an event.
+func (s *Stream) Send(e Event) error {
+	if strings.ContainsAny(e.ID, "\r\n\x00") || strings.ContainsAny(e.Event, "\r\n") {
+		return ErrInvalidField
+	}
+	var b strings.Builder
+	if e.Event != "" {
+		b.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Data != "" {
+		writeLines(&b, "data: ", e.Data)
+	}
+	if e.ID != "" {
+		b.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Comment writes a comment line, which clients ignore.
+func (s *Stream) Comment(text string) error {
+	var b strings.Builder
+	writeLines(&b, ": ", text)
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// writeLines writes each line of text as its own field. CRLF, CR and LF all
+// end a line in an event stream.
+func writeLines(b *strings.Builder, prefix, text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(prefix + line + "\n")
+	}
+}
+
+func (s *Stream) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if _, err := s.cw.Write([]byte(data)); err != nil {
+		s.end(ErrStreamClosed)
+		return err
+	}
+	return nil
+}
+
+// Close ends the stream, writing the last chunk.
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil
+	}
+	s.end(ErrStreamClosed)
+	return s.cw.Close()
+}
+
+func (s *Stream) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.end(err)
+	}
+}
+
+// end marks the stream as finished. It must be called with mu held.
+func (s *Stream) end(err error) {
+	s.err = err
+	s.cancel()
+	close(s.done)
+}
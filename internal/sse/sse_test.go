@@ -0,0 +1,145 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/request"
+	"http/internal/response"
+)
+
+// syncBuffer is a bytes.Buffer safe for the heartbeat goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	err error
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newStream(t *testing.T, out *syncBuffer, header string, interval time.Duration) (*Stream, context.CancelFunc) {
+	t.Helper()
+	r, err := request.RequestFromReader(strings.NewReader("GET /events HTTP/1.1\r\n" + header + "\r\n"))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	r.SetContext(ctx)
+	s, err := NewWithHeartbeat(response.NewWriter(out), r, interval)
+	require.NoError(t, err)
+	return s, cancel
+}
+
+func TestStream(t *testing.T) {
+	out := &syncBuffer{}
+	s, cancel := newStream(t, out, "Last-Event-ID: 41\r\n", 0)
+	defer cancel()
+	assert.Equal(t, "41", s.LastEventID())
+
+	require.NoError(t, s.Send(Event{ID: "42", Event: "update", Data: "line one\nline two\r\nline three", Retry: 3 * time.Second}))
+	require.NoError(t, s.Send(Event{Data: "x"}))
+	require.NoError(t, s.Comment("hi"))
+	require.ErrorIs(t, s.Send(Event{ID: "4\n2"}), ErrInvalidField)
+	require.NoError(t, s.Close())
+	require.ErrorIs(t, s.Send(Event{Data: "late"}), ErrStreamClosed)
+
+	event := "event: update\n" +
+		"data: line one\n" +
+		"data: line two\n" +
+		"data: line three\n" +
+		"id: 42\n" +
+		"retry: 3000\n\n"
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\r\n"+
+			"cache-control: no-store\r\n"+
+			"connection: close\r\n"+
+			"content-type: text/event-stream\r\n"+
+			"transfer-encoding: chunked\r\n\r\n"+
+			fmt.Sprintf("%x\r\n", len(event))+event+"\r\n"+
+			"9\r\ndata: x\n\n\r\n"+
+			"6\r\n: hi\n\n\r\n"+
+			"0\r\n\r\n",
+		out.String())
+}
+
+func TestHeartbeat(t *testing.T) {
+	out := &syncBuffer{}
+	s, cancel := newStream(t, out, "", 10*time.Millisecond)
+	defer cancel()
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), ": heartbeat\n\n")
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, s.Close())
+}
+
+func TestDisconnect(t *testing.T) {
+	// Test: Request context cancelled by the server
+	out := &syncBuffer{}
+	s, cancel := newStream(t, out, "", 0)
+	cancel()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("stream not done after disconnect")
+	}
+	require.ErrorIs(t, s.Send(Event{Data: "x"}), ErrStreamClosed)
+
+	// Test: Heartbeat failing on a broken connection
+	out = &syncBuffer{}
+	s, cancel = newStream(t, out, "", 10*time.Millisecond)
+	defer cancel()
+	out.mu.Lock()
+	out.err = errors.New("broken pipe")
+	out.mu.Unlock()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("stream not done after write failure")
+	}
+}
+
+func TestHandlerReturnsWithoutClose(t *testing.T) {
+	out := &syncBuffer{}
+	r, err := request.RequestFromReader(strings.NewReader("GET /events HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+	w := response.NewWriter(out)
+	s, err := NewWithHeartbeat(w, r, time.Millisecond)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), ": heartbeat\n\n")
+	}, time.Second, time.Millisecond)
+
+	// Test: Finish stops the heartbeat before ending the chunked body
+	require.NoError(t, w.Finish())
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("stream still running after Finish")
+	}
+	time.Sleep(20 * time.Millisecond)
+	_, body, ok := strings.Cut(out.String(), "\r\n\r\n")
+	require.True(t, ok)
+	assert.True(t, strings.HasSuffix(body, "\r\n0\r\n\r\n"), body)
+	assert.Equal(t, 1, strings.Count(body, "0\r\n\r\n"), body)
+	require.ErrorIs(t, s.Send(Event{Data: "late"}), ErrStreamClosed)
+}
@@ -0,0 +1,458 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"unicode/utf8"
+)
+
+const DefaultMaxMessageSize = 1 << 20
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// Close status codes from RFC 6455 section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseAbnormal        = 1006
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	maxControlPayload = 125
+)
+
+var (
+	ErrCloseSent       = errors.New("websocket: close frame already sent")
+	ErrInvalidUTF8     = errors.New("websocket: text message is not valid UTF-8")
+	ErrControlTooLarge = errors.New("websocket: control frame payload too large")
+)
+
+var errMessageTooBig = &protocolError{code: CloseMessageTooBig, reason: "message too big"}
+
+// CloseError is returned by ReadMessage once the peer has closed the
+// connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Reason)
+}
+
+// protocolError is a violation by the peer. The connection is failed with
+// the close code.
+type protocolError struct {
+	code   int
+	reason string
+}
+
+func (e *protocolError) Error() string {
+	return "websocket: " + e.reason
+}
+
+// Conn is a WebSocket connection. One goroutine may read while others write;
+// writes are serialised. Control frames are handled by ReadMessage, so it
+// must be called in a loop for pings to be answered and closes to complete.
+type Conn struct {
+	conn           net.Conn
+	br             *bufio.Reader
+	isServer       bool
+	compress       bool
+	maxMessageSize int64
+	fragmentSize   int
+	subprotocol    string
+	pongHandler    func(data []byte)
+
+	writeMu   sync.Mutex
+	closeSent bool
+	readErr   error
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer, compress bool, maxMessageSize int64) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
+	}
+	return &Conn{
+		conn:           conn,
+		br:             br,
+		isServer:       isServer,
+		compress:       compress,
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// Subprotocol returns the negotiated subprotocol, if any.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Compressed reports whether permessage-deflate was negotiated.
+func (c *Conn) Compressed() bool {
+	return c.compress
+}
+
+// SetFragmentSize makes WriteMessage split messages into frames of at most
+// n payload bytes. Zero sends every message in a single frame.
+func (c *Conn) SetFragmentSize(n int) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.fragmentSize = max(n, 0)
+}
+
+// SetPongHandler sets a function called by ReadMessage for every pong.
+func (c *Conn) SetPongHandler(fn func(data []byte)) {
+	c.pongHandler = fn
+}
+
+// NetConn returns the underlying connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+type frame struct {
+	fin     bool
+	rsv1    bool
+	opcode  byte
+	payload []byte
+}
+
+// ReadMessage returns the next data message, reassembling fragments. Pings
+// are answered with pongs. When the peer closes the connection the close is
+// acknowledged and a *CloseError is returned.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	msgType, data, err := c.readMessage()
+	if err != nil {
+		c.readErr = c.handleReadError(err)
+		return 0, nil, c.readErr
+	}
+	return msgType, data, nil
+}
+
+func (c *Conn) readMessage() (MessageType, []byte, error) {
+	var msgType MessageType
+	var data []byte
+	started, compressed := false, false
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch f.opcode {
+		case opPing:
+			if err := c.writeControl(opPong, f.payload); err != nil && !errors.Is(err, ErrCloseSent) {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			if c.pongHandler != nil {
+				c.pongHandler(f.payload)
+			}
+			continue
+		case opClose:
+			return 0, nil, parseClose(f.payload)
+		case opText, opBinary:
+			if started {
+				return 0, nil, &protocolError{code: CloseProtocolError, reason: "expected continuation frame"}
+			}
+			started, compressed = true, f.rsv1
+			msgType = MessageType(f.opcode)
+		case opContinuation:
+			if !started {
+				return 0, nil, &protocolError{code: CloseProtocolError, reason: "unexpected continuation frame"}
+			}
+			if f.rsv1 {
+				return 0, nil, &protocolError{code: CloseProtocolError, reason: "RSV1 set on continuation frame"}
+			}
+		default:
+			return 0, nil, &protocolError{code: CloseProtocolError, reason: "unknown opcode"}
+		}
+		if int64(len(data)+len(f.payload)) > c.maxMessageSize {
+			return 0, nil, errMessageTooBig
+		}
+		data = append(data, f.payload...)
+		if f.fin {
+			break
+		}
+	}
+	if compressed {
+		var err error
+		if data, err = inflate(data, c.maxMessageSize); err != nil {
+			return 0, nil, err
+		}
+	}
+	if msgType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, &protocolError{code: CloseInvalidPayload, reason: "invalid UTF-8 in text message"}
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return msgType, data, nil
+}
+
+// handleReadError completes the closing handshake for closes and protocol
+// errors and closes the connection.
+func (c *Conn) handleReadError(err error) error {
+	var closeErr *CloseError
+	var protoErr *protocolError
+	switch {
+	case errors.As(err, &closeErr):
+		code := closeErr.Code
+		if code == CloseNoStatus {
+			code = CloseNormal
+		}
+		c.WriteClose(code, "")
+	case errors.As(err, &protoErr):
+		c.WriteClose(protoErr.code, protoErr.reason)
+	}
+	c.conn.Close()
+	return err
+}
+
+func (c *Conn) readFrame() (*frame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return nil, err
+	}
+	f := &frame{
+		fin:    head[0]&0x80 != 0,
+		rsv1:   head[0]&0x40 != 0,
+		opcode: head[0] & 0x0f,
+	}
+	control := f.opcode&0x8 != 0
+	switch {
+	case head[0]&0x30 != 0:
+		return nil, &protocolError{code: CloseProtocolError, reason: "reserved bits set"}
+	case f.rsv1 && (!c.compress || control):
+		return nil, &protocolError{code: CloseProtocolError, reason: "RSV1 set without compression"}
+	case control && !f.fin:
+		return nil, &protocolError{code: CloseProtocolError, reason: "fragmented control frame"}
+	}
+	masked := head[1]&0x80 != 0
+	if masked != c.isServer {
+		return nil, &protocolError{code: CloseProtocolError, reason: "invalid frame masking"}
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+		if length>>63 != 0 {
+			return nil, &protocolError{code: CloseProtocolError, reason: "invalid payload length"}
+		}
+	}
+	if control && length > maxControlPayload {
+		return nil, &protocolError{code: CloseProtocolError, reason: "control frame too large"}
+	}
+	if length > uint64(c.maxMessageSize) {
+		return nil, errMessageTooBig
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return nil, err
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return nil, err
+	}
+	if masked {
+		maskBytes(mask, f.payload)
+	}
+	return f, nil
+}
+
+func parseClose(payload []byte) error {
+	switch {
+	case len(payload) == 0:
+		return &CloseError{Code: CloseNoStatus}
+	case len(payload) == 1:
+		return &protocolError{code: CloseProtocolError, reason: "invalid close payload"}
+	}
+	code := int(binary.BigEndian.Uint16(payload))
+	if !validCloseCode(code) {
+		return &protocolError{code: CloseProtocolError, reason: "invalid close code"}
+	}
+	reason := payload[2:]
+	if !utf8.Valid(reason) {
+		return &protocolError{code: CloseInvalidPayload, reason: "invalid UTF-8 in close reason"}
+	}
+	return &CloseError{Code: code, Reason: string(reason)}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// WriteMessage sends a data message, compressing it if permessage-deflate
+// was negotiated and fragmenting it according to SetFragmentSize.
+func (c *Conn) WriteMessage(msgType MessageType, data []byte) error {
+	if msgType != TextMessage && msgType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", msgType)
+	}
+	if msgType == TextMessage && !utf8.Valid(data) {
+		return ErrInvalidUTF8
+	}
+	compressed := false
+	if c.compress {
+		var err error
+		if data, err = deflate(data); err != nil {
+			return err
+		}
+		compressed = true
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	opcode := byte(msgType)
+	for first := true; ; first = false {
+		n := len(data)
+		if c.fragmentSize > 0 && n > c.fragmentSize {
+			n = c.fragmentSize
+		}
+		fin := n == len(data)
+		if err := c.writeFrame(fin, compressed && first, opcode, data[:n]); err != nil {
+			return err
+		}
+		if fin {
+			return nil
+		}
+		data = data[n:]
+		opcode = opContinuation
+	}
+}
+
+// Ping sends a ping with an optional payload of at most 125 bytes.
+func (c *Conn) Ping(data []byte) error {
+	return c.writeControl(opPing, data)
+}
+
+// WriteClose starts or completes the closing handshake. After starting it,
+// keep calling ReadMessage until it returns the peer's *CloseError.
+func (c *Conn) WriteClose(code int, reason string) error {
+	var payload []byte
+	if code != CloseNoStatus {
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+	}
+	return c.writeControl(opClose, payload)
+}
+
+// Close closes the underlying connection without a closing handshake.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Conn) writeControl(opcode byte, payload []byte) error {
+	if len(payload) > maxControlPayload {
+		return ErrControlTooLarge
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+	return c.writeFrame(true, false, opcode, payload)
+}
+
+// writeFrame writes a single frame. Clients mask every frame they send.
+func (c *Conn) writeFrame(fin, rsv1 bool, opcode byte, payload []byte) error {
+	buf := make([]byte, 0, 14+len(payload))
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	buf = append(buf, b0)
+	var maskBit byte
+	if !c.isServer {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	if c.isServer {
+		buf = append(buf, payload...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		buf = append(buf, mask[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		maskBytes(mask, buf[start:])
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
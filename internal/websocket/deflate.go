@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"strings"
+
+	"http/internal/headers"
+)
+
+// deflateResponse accepts permessage-deflate (RFC 7692) without context
+// takeover in either direction, so every message is compressed on its own.
+const deflateResponse = "permessage-deflate; server_no_context_takeover; client_no_context_takeover"
+
+// deflateTail is removed from the end of compressed messages by the sender
+// and added back by the receiver. The final empty stored block makes the
+// decompressor stop at the end of the message.
+var (
+	deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+	deflateEnd  = []byte{0x01, 0x00, 0x00, 0xff, 0xff}
+)
+
+// acceptDeflate reports whether one of the offers in a
+// Sec-WebSocket-Extensions header is a permessage-deflate configuration we
+// can honour.
+func acceptDeflate(extensions string) bool {
+	for _, offer := range headers.SplitList(extensions) {
+		params := headers.SplitParameters(offer)
+		if !strings.EqualFold(strings.TrimSpace(params[0]), "permessage-deflate") {
+			continue
+		}
+		if deflateParamsOK(params[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func deflateParamsOK(params []string) bool {
+	seen := map[string]bool{}
+	for _, param := range params {
+		name, value, _ := strings.Cut(param, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := headers.Unquote(value)
+			if err != nil {
+				return false
+			}
+			value = unquoted
+		}
+		if seen[name] {
+			return false
+		}
+		seen[name] = true
+		switch name {
+		case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+		case "server_max_window_bits":
+			// the flate package always uses a 32KB window
+			if value != "15" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+// inflate decompresses a message, failing with errMessageTooBig if it is
+// larger than maxSize.
+func inflate(data []byte, maxSize int64) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(
+		bytes.NewReader(data),
+		bytes.NewReader(deflateTail),
+		bytes.NewReader(deflateEnd),
+	))
+	defer fr.Close()
+	out, err := io.ReadAll(io.LimitReader(fr, maxSize+1))
+	if err != nil {
+		return nil, &protocolError{code: CloseInvalidPayload, reason: "invalid compressed data"}
+	}
+	if int64(len(out)) > maxSize {
+		return nil, errMessageTooBig
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"http/internal/headers"
+	"http/internal/request"
+	"http/internal/response"
+)
+
+// acceptGUID is appended to the client's key to compute Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	ErrBadHandshake     = errors.New("websocket: bad handshake")
+	ErrOriginNotAllowed = errors.New("websocket: origin not allowed")
+)
+
+// Upgrader turns HTTP requests into WebSocket connections.
+type Upgrader struct {
+	// Subprotocols lists the supported subprotocols in order of preference.
+	Subprotocols []string
+	// CheckOrigin reports whether the request's Origin is allowed. When
+	// nil, browsers may only connect from the same host.
+	CheckOrigin func(r *request.Request) bool
+	// MaxMessageSize is the largest message accepted, after decompression.
+	// Zero means DefaultMaxMessageSize.
+	MaxMessageSize int64
+	// EnableCompression negotiates permessage-deflate if the client
+	// offers it.
+	EnableCompression bool
+}
+
+// Upgrade performs the opening handshake and hijacks the connection. When
+// the request is not a valid WebSocket handshake an error response has been
+// written and an error is returned.
+func (u *Upgrader) Upgrade(w *response.Writer, r *request.Request) (*Conn, error) {
+	if r.RequestLine.Method != "GET" {
+		h := response.GetDefaultHeaders(0)
+		h.Set("Allow", "GET")
+		return nil, writeError(w, response.StatusMethodNotAllowed, h)
+	}
+	if !r.OffersUpgrade("websocket") {
+		return nil, writeError(w, response.StatusBadRequest, nil)
+	}
+	if r.Headers.Get("Sec-WebSocket-Version") != "13" {
+		h := response.GetDefaultHeaders(0)
+		h.Set("Sec-WebSocket-Version", "13")
+		return nil, writeError(w, response.StatusUpgradeRequired, h)
+	}
+	key := r.Headers.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, writeError(w, response.StatusBadRequest, nil)
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		writeError(w, response.StatusForbidden, nil)
+		return nil, ErrOriginNotAllowed
+	}
+
+	h := headers.NewHeaders()
+	h.Set("Sec-WebSocket-Accept", acceptKey(key))
+	subprotocol := u.selectSubprotocol(r)
+	if subprotocol != "" {
+		h.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+	compress := u.EnableCompression && acceptDeflate(r.Headers.Get("Sec-WebSocket-Extensions"))
+	if compress {
+		h.Set("Sec-WebSocket-Extensions", deflateResponse)
+	}
+	if err := w.SwitchProtocols("websocket", h); err != nil {
+		return nil, err
+	}
+	conn, buffered, err := w.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(io.MultiReader(bytes.NewReader(buffered), conn))
+	c := newConn(conn, br, true, compress, u.MaxMessageSize)
+	c.subprotocol = subprotocol
+	return c, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (u *Upgrader) selectSubprotocol(r *request.Request) string {
+	offered := headers.SplitList(r.Headers.Get("Sec-WebSocket-Protocol"))
+	for _, supported := range u.Subprotocols {
+		for _, p := range offered {
+			if p == supported {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// sameOrigin accepts requests without an Origin header, which do not come
+// from browsers, and requests whose Origin host matches the Host header.
+func sameOrigin(r *request.Request) bool {
+	origin := r.Headers.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Headers.Get("Host"))
+}
+
+func writeError(w *response.Writer, statusCode response.StatusCode, h headers.Headers) error {
+	if h == nil {
+		h = response.GetDefaultHeaders(0)
+	}
+	if err := w.WriteStatusLine(statusCode); err != nil {
+		return err
+	}
+	if err := w.WriteHeaders(h); err != nil {
+		return err
+	}
+	return ErrBadHandshake
+}
@@ -0,0 +1,236 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"http/internal/request"
+	"http/internal/response"
+	"http/internal/server"
+)
+
+// echoServer starts a server upgrading every request with u and echoing
+// messages until the client closes.
+func echoServer(t *testing.T, u *Upgrader) string {
+	t.Helper()
+	s, err := server.Serve(0, func(w *response.Writer, r *request.Request) {
+		c, err := u.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			msgType, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if c.WriteMessage(msgType, data) != nil {
+				return
+			}
+		}
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s.Addr().String()
+}
+
+const testKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// dial performs the client side of the handshake and returns the raw
+// response head and, on a 101, a client Conn.
+func dial(t *testing.T, addr string, extra string) (string, *Conn) {
+	t.Helper()
+	nc, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { nc.Close() })
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = nc.Write([]byte("GET /chat HTTP/1.1\r\n" +
+		"Host: " + addr + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + testKey + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n" +
+		extra + "\r\n"))
+	require.NoError(t, err)
+	br := bufio.NewReader(nc)
+	var head strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		head.WriteString(line)
+		if line == "\r\n" {
+			break
+		}
+	}
+	if !strings.HasPrefix(head.String(), "HTTP/1.1 101 ") {
+		return head.String(), nil
+	}
+	compress := strings.Contains(head.String(), "sec-websocket-extensions: permessage-deflate")
+	return head.String(), newConn(nc, br, false, compress, 0)
+}
+
+func TestHandshake(t *testing.T) {
+	addr := echoServer(t, &Upgrader{Subprotocols: []string{"chat.v2", "chat"}})
+
+	// Test: Accept key from RFC 6455 section 1.3 and subprotocol selection
+	head, c := dial(t, addr, "Sec-WebSocket-Protocol: chat, chat.v2\r\n")
+	require.NotNil(t, c, head)
+	assert.Contains(t, head, "sec-websocket-accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\n")
+	assert.Contains(t, head, "sec-websocket-protocol: chat.v2\r\n")
+	assert.Contains(t, head, "upgrade: websocket\r\n")
+	assert.NotContains(t, head, "sec-websocket-extensions")
+
+	// Test: Unsupported version
+	head, c = dial(t, addr, "Sec-WebSocket-Version: 8\r\n")
+	assert.Nil(t, c)
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 400 ") || strings.HasPrefix(head, "HTTP/1.1 426 "), head)
+
+	// Test: Cross origin request
+	head, c = dial(t, addr, "Origin: http://evil.example\r\n")
+	assert.Nil(t, c)
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 403 "), head)
+}
+
+func TestEcho(t *testing.T) {
+	addr := echoServer(t, &Upgrader{})
+	_, c := dial(t, addr, "")
+	require.NotNil(t, c)
+
+	// Test: Text and binary messages of every length encoding
+	for _, size := range []int{0, 5, 125, 126, 65535, 70000} {
+		msg := bytes.Repeat([]byte("a"), size)
+		require.NoError(t, c.WriteMessage(TextMessage, msg))
+		msgType, data, err := c.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, TextMessage, msgType)
+		assert.Equal(t, msg, data)
+	}
+	require.NoError(t, c.WriteMessage(BinaryMessage, []byte{0, 1, 2}))
+	msgType, data, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, BinaryMessage, msgType)
+	assert.Equal(t, []byte{0, 1, 2}, data)
+
+	// Test: Fragmented message with a ping in between is reassembled
+	c.SetFragmentSize(4)
+	var pong []byte
+	c.SetPongHandler(func(data []byte) { pong = data })
+	c.writeMu.Lock()
+	require.NoError(t, c.writeFrame(false, false, opText, []byte("frag")))
+	require.NoError(t, c.writeFrame(true, false, opPing, []byte("are you there")))
+	require.NoError(t, c.writeFrame(true, false, opContinuation, []byte("mented")))
+	c.writeMu.Unlock()
+	_, data, err = c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "fragmented", string(data))
+	assert.Equal(t, "are you there", string(pong))
+
+	// Test: Close handshake
+	require.NoError(t, c.WriteClose(CloseNormal, "bye"))
+	_, _, err = c.ReadMessage()
+	var closeErr *CloseError
+	require.True(t, errors.As(err, &closeErr), err)
+	assert.Equal(t, CloseNormal, closeErr.Code)
+	require.ErrorIs(t, c.WriteMessage(TextMessage, []byte("late")), ErrCloseSent)
+}
+
+func TestProtocolErrors(t *testing.T) {
+	addr := echoServer(t, &Upgrader{MaxMessageSize: 16})
+	expectClose := func(c *Conn, code int) {
+		t.Helper()
+		_, _, err := c.ReadMessage()
+		var closeErr *CloseError
+		require.True(t, errors.As(err, &closeErr), err)
+		assert.Equal(t, code, closeErr.Code)
+	}
+
+	// Test: Message too big
+	_, c := dial(t, addr, "")
+	require.NotNil(t, c)
+	require.NoError(t, c.WriteMessage(BinaryMessage, make([]byte, 17)))
+	expectClose(c, CloseMessageTooBig)
+
+	// Test: Fragments adding up to more than the limit
+	_, c = dial(t, addr, "")
+	c.SetFragmentSize(10)
+	require.NoError(t, c.WriteMessage(BinaryMessage, make([]byte, 20)))
+	expectClose(c, CloseMessageTooBig)
+
+	// Test: Invalid UTF-8 in a text message
+	_, c = dial(t, addr, "")
+	c.writeMu.Lock()
+	require.NoError(t, c.writeFrame(true, false, opText, []byte{0xff, 0xfe}))
+	c.writeMu.Unlock()
+	expectClose(c, CloseInvalidPayload)
+
+	// Test: Unmasked client frame
+	_, c = dial(t, addr, "")
+	c.isServer = true
+	c.writeMu.Lock()
+	require.NoError(t, c.writeFrame(true, false, opText, []byte("hi")))
+	c.writeMu.Unlock()
+	c.isServer = false
+	expectClose(c, CloseProtocolError)
+
+	// Test: Continuation without a first frame
+	_, c = dial(t, addr, "")
+	c.writeMu.Lock()
+	require.NoError(t, c.writeFrame(true, false, opContinuation, []byte("hi")))
+	c.writeMu.Unlock()
+	expectClose(c, CloseProtocolError)
+}
+
+func TestPermessageDeflate(t *testing.T) {
+	addr := echoServer(t, &Upgrader{EnableCompression: true, MaxMessageSize: 1 << 16})
+
+	// Test: Negotiated and round tripped
+	head, c := dial(t, addr, "Sec-WebSocket-Extensions: permessage-deflate; client_max_window_bits\r\n")
+	require.NotNil(t, c, head)
+	assert.True(t, c.Compressed())
+	msg := strings.Repeat("compress me please ", 200)
+	require.NoError(t, c.WriteMessage(TextMessage, []byte(msg)))
+	_, data, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, msg, string(data))
+	require.NoError(t, c.WriteMessage(TextMessage, []byte{}))
+	_, data, err = c.ReadMessage()
+	require.NoError(t, err)
+	assert.Empty(t, data)
+
+	// Test: Decompression bombs hit the message size limit
+	require.NoError(t, c.WriteMessage(BinaryMessage, make([]byte, 1<<17)))
+	_, _, err = c.ReadMessage()
+	var closeErr *CloseError
+	require.True(t, errors.As(err, &closeErr), err)
+	assert.Equal(t, CloseMessageTooBig, closeErr.Code)
+
+	// Test: Offers we cannot honour are declined
+	head, c = dial(t, addr, "Sec-WebSocket-Extensions: permessage-deflate; server_max_window_bits=10\r\n")
+	require.NotNil(t, c, head)
+	assert.False(t, c.Compressed())
+}
+
+func TestAcceptDeflate(t *testing.T) {
+	assert.True(t, acceptDeflate("permessage-deflate"))
+	assert.True(t, acceptDeflate(`permessage-deflate; server_max_window_bits="15"`))
+	assert.True(t, acceptDeflate("x-webkit-deflate-frame, permessage-deflate; server_max_window_bits=10, permessage-deflate"))
+	assert.False(t, acceptDeflate(`permessage-deflate; server_max_window_bits="10"`))
+	assert.False(t, acceptDeflate("permessage-deflate; client_no_context_takeover; client_no_context_takeover"))
+	assert.False(t, acceptDeflate(`permessage-deflate; server_max_window_bits="15`))
+	assert.False(t, acceptDeflate("x-webkit-deflate-frame"))
+}
+
+func TestAcceptKey(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("the sample nonce"))
+	assert.Equal(t, testKey, key)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey(key))
+}